@@ -6,6 +6,7 @@ import (
 	"go.viam.com/rdk/components/switch"
 	"go.viam.com/rdk/module"
 	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/services/generic"
 	"go.viam.com/rdk/services/vision"
 
 	"github.com/erh/vmodutils/touch"
@@ -16,10 +17,13 @@ func main() {
 		resource.APIModel{camera.API, touch.CropCameraModel},
 		resource.APIModel{camera.API, touch.MergeModel},
 		resource.APIModel{camera.API, touch.MultipleArmPosesModel},
+		resource.APIModel{camera.API, touch.VoxelFilterCameraModel},
 		resource.APIModel{toggleswitch.API, touch.ArmPositionSaverModel},
+		resource.APIModel{toggleswitch.API, touch.ArmTrajectoryModel},
 		resource.APIModel{gripper.API, touch.ObstacleModel},
 		resource.APIModel{gripper.API, touch.ObstacleOpenBoxModel},
 		resource.APIModel{vision.API, touch.ClusterModel},
+		resource.APIModel{generic.API, touch.ScanPlannerModel},
 	)
 
 }
@@ -42,9 +42,15 @@ func realMain() error {
 	colorGreen := flag.Int("color-green", 255, "")
 	colorBlue := flag.Int("color-blue", 255, "")
 
+	clusterAlgorithm := flag.String("algorithm", touch.ClusterAlgorithmVoxel, "voxel, dbscan, or region-growing")
 	maxDistance := flag.Float64("max-distance", 30, "")
 	minPointsPerSegment := flag.Int("min-points-per-segment", 20, "")
 	minPointsPerCluster := flag.Int("min-points-per-cluster", 100, "")
+	normalRadius := flag.Float64("normal-radius", 0, "region-growing only")
+	maxNormalAngleDegs := flag.Float64("max-normal-angle-degs", 0, "region-growing only")
+	maxCurvature := flag.Float64("max-curvature", 0, "region-growing only")
+	maxColorDistance := flag.Float64("max-color-distance", 0, "region-growing only")
+	removePlaneDistance := flag.Float64("remove-plane-distance", 0, "if set, remove the largest plane before clustering")
 
 	flag.Parse()
 
@@ -68,7 +74,7 @@ func realMain() error {
 			return err
 		}
 
-		pc, err := myCamera.NextPointCloud(ctx)
+		pc, err := myCamera.NextPointCloud(ctx, nil)
 		if err != nil {
 			return err
 		}
@@ -88,7 +94,7 @@ func realMain() error {
 			return err
 		}
 
-		pc, err := myCamera.NextPointCloud(ctx)
+		pc, err := myCamera.NextPointCloud(ctx, nil)
 		if err != nil {
 			return err
 		}
@@ -186,7 +192,21 @@ func realMain() error {
 			return err
 		}
 
-		clusters, err := touch.Cluster(in, *maxDistance, *minPointsPerSegment, *minPointsPerCluster)
+		cc := &touch.ClusterConfig{
+			Algorithm:           *clusterAlgorithm,
+			MaxDistance:         *maxDistance,
+			MinPointsPerSegment: *minPointsPerSegment,
+			MinPointsPerCluster: *minPointsPerCluster,
+			NormalRadius:        *normalRadius,
+			MaxNormalAngleDegs:  *maxNormalAngleDegs,
+			MaxCurvature:        *maxCurvature,
+			MaxColorDistance:    *maxColorDistance,
+		}
+		if *removePlaneDistance > 0 {
+			cc.RemovePlane = &touch.PlaneRemovalConfig{Distance: *removePlaneDistance}
+		}
+
+		clusters, err := cc.Cluster(in)
 		if err != nil {
 			return err
 		}
@@ -7,6 +7,7 @@ require (
 	go.viam.com/rdk v0.98.1-0.20251023194042-e97069d07515
 	go.viam.com/test v1.2.4
 	go.viam.com/utils v0.1.174
+	google.golang.org/protobuf v1.36.8
 	neilpa.me/go-stl v0.5.0
 )
 
@@ -210,7 +211,6 @@ require (
 	google.golang.org/genproto/googleapis/api v0.0.0-20250707201910-8d1bb00bc6a7 // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20250818200422-3122310a409c // indirect
 	google.golang.org/grpc v1.75.0 // indirect
-	google.golang.org/protobuf v1.36.8 // indirect
 	gopkg.in/natefinch/lumberjack.v2 v2.2.1 // indirect
 	gopkg.in/warnings.v0 v0.1.2 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
@@ -4,6 +4,9 @@ import (
 	"encoding/json"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+
+	"go.viam.com/rdk/utils"
 )
 
 func ReadJSONFromFile(fn string, where any) error {
@@ -11,6 +14,7 @@ func ReadJSONFromFile(fn string, where any) error {
 	if err != nil {
 		return err
 	}
+	defer f.Close()
 
 	jsonData, err := ioutil.ReadAll(f)
 	if err != nil {
@@ -19,3 +23,46 @@ func ReadJSONFromFile(fn string, where any) error {
 
 	return json.Unmarshal(jsonData, where)
 }
+
+// WriteJSONToFile writes v to fn as json. It writes to a temp file first and renames it,
+// so fn is never left half written.
+func WriteJSONToFile(fn string, v any) error {
+	jsonData, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(fn), filepath.Base(fn)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name()) // fails harmlessly once renamed
+
+	_, err = f.Write(jsonData)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), fn)
+}
+
+// ToAttributeMap converts a config struct to an AttributeMap by round tripping through json.
+func ToAttributeMap(v any) (utils.AttributeMap, error) {
+	jsonData, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	m := utils.AttributeMap{}
+	err = json.Unmarshal(jsonData, &m)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
@@ -0,0 +1,55 @@
+package vmodutils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.viam.com/test"
+)
+
+func TestToAttributeMap(t *testing.T) {
+	type inner struct {
+		X float64
+	}
+	type cfg struct {
+		Name  string `json:"name"`
+		Inner inner  `json:"inner"`
+		Skip  string `json:"skip,omitempty"`
+	}
+
+	m, err := ToAttributeMap(&cfg{Name: "a", Inner: inner{X: 5}})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, m["name"], test.ShouldEqual, "a")
+	test.That(t, m["inner"], test.ShouldResemble, map[string]interface{}{"X": 5.0})
+	_, ok := m["skip"]
+	test.That(t, ok, test.ShouldBeFalse)
+
+	_, err = ToAttributeMap(func() {})
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func TestWriteJSONToFile(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "x.json")
+
+	err := WriteJSONToFile(fn, map[string]interface{}{"a": 1})
+	test.That(t, err, test.ShouldBeNil)
+	err = WriteJSONToFile(fn, map[string]interface{}{"a": 2})
+	test.That(t, err, test.ShouldBeNil)
+
+	m := map[string]interface{}{}
+	err = ReadJSONFromFile(fn, &m)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, m["a"], test.ShouldEqual, 2.0)
+
+	// no temp files left behind
+	files, err := os.ReadDir(filepath.Dir(fn))
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(files), test.ShouldEqual, 1)
+
+	err = WriteJSONToFile(filepath.Join(fn, "nope", "x.json"), 1)
+	test.That(t, err, test.ShouldNotBeNil)
+
+	err = WriteJSONToFile(fn, func() {})
+	test.That(t, err, test.ShouldNotBeNil)
+}
@@ -121,6 +121,75 @@ func UpdateComponentCloudAttributes(ctx context.Context, c *app.AppClient, id st
 	return err
 }
 
+// AddComponentsCloudFromModuleEnv adds components to the machine part the module is running on.
+// Each component is a config like in the app, at least name, api, and model are needed.
+func AddComponentsCloudFromModuleEnv(ctx context.Context, components []map[string]interface{}, logger logging.Logger) error {
+	id := os.Getenv(utils.MachinePartIDEnvVar)
+	if id == "" {
+		return fmt.Errorf("no %s in env", utils.MachinePartIDEnvVar)
+	}
+
+	c, err := app.CreateViamClientFromEnvVars(ctx, nil, logger)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	return AddComponentsCloud(ctx, c.AppClient(), id, components)
+}
+
+func AddComponentsCloud(ctx context.Context, c *app.AppClient, id string, components []map[string]interface{}) error {
+	part, _, err := c.GetRobotPart(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err = addComponentsInPlace(part.RobotConfig, components); err != nil {
+		return err
+	}
+
+	_, err = c.UpdateRobotPart(ctx, id, part.Name, part.RobotConfig)
+	return err
+}
+
+func addComponentsInPlace(robotConfig map[string]interface{}, components []map[string]interface{}) error {
+	if robotConfig == nil {
+		return fmt.Errorf("no machine config")
+	}
+
+	cs, ok := robotConfig["components"].([]interface{})
+	if !ok {
+		if robotConfig["components"] != nil {
+			return fmt.Errorf("no components %T", robotConfig["components"])
+		}
+		cs = []interface{}{}
+	}
+
+	names := map[string]bool{}
+	for idx, cc := range cs {
+		ccc, ok := cc.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("config bad %d: %T", idx, cc)
+		}
+		names[fmt.Sprintf("%v", ccc["name"])] = true
+	}
+
+	for _, c := range components {
+		name, ok := c["name"].(string)
+		if !ok || name == "" {
+			return fmt.Errorf("component needs a name: %v", c)
+		}
+		if names[name] {
+			return fmt.Errorf("already have a component named %s", name)
+		}
+		names[name] = true
+		cs = append(cs, c)
+	}
+
+	robotConfig["components"] = cs
+	return nil
+}
+
 func updateComponentAttributesInPlace(ctx context.Context, robotConfig map[string]interface{}, getFragmentFunc func(context.Context, string, string) (*app.Fragment, error), name resource.Name, newAttr utils.AttributeMap) error {
 	found, err := updateComponentOrServiceConfig(robotConfig, name, newAttr)
 	if err != nil {
@@ -270,3 +270,28 @@ func TestGetFragmentId(t *testing.T) {
 		})
 	}
 }
+
+func TestAddComponentsInPlace(t *testing.T) {
+	machine := helperMachineConfig([]string{"c1"}, []string{"s1"}, nil)
+
+	err := addComponentsInPlace(machine, []map[string]interface{}{
+		{"name": "c2", "api": "rdk:component:switch", "attributes": map[string]interface{}{"a": 1}},
+		{"name": "c3"},
+	})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(machine["components"].([]interface{})), test.ShouldEqual, 3)
+	test.That(t, getAttrFromConfigForTests(machine, "c2"), test.ShouldResemble, map[string]interface{}{"a": 1})
+
+	err = addComponentsInPlace(machine, []map[string]interface{}{{"name": "c4"}, {"name": "c1"}})
+	test.That(t, err, test.ShouldNotBeNil)
+	err = addComponentsInPlace(machine, []map[string]interface{}{{"api": "x"}})
+	test.That(t, err, test.ShouldNotBeNil)
+
+	empty := map[string]interface{}{}
+	err = addComponentsInPlace(empty, []map[string]interface{}{{"name": "c1"}})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(empty["components"].([]interface{})), test.ShouldEqual, 1)
+
+	err = addComponentsInPlace(nil, []map[string]interface{}{{"name": "c1"}})
+	test.That(t, err, test.ShouldNotBeNil)
+}
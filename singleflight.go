@@ -0,0 +1,116 @@
+package vmodutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// SingleFlight runs one call at a time. Callers that show up while a call is running wait for it
+// instead of starting their own, so the result they get always finished after they asked.
+// The zero value is ready to use.
+type SingleFlight[T any] struct {
+	// Timeout is how long a caller waits for someone else's call, 0 means only the context is used.
+	Timeout time.Duration
+
+	lock     sync.Mutex
+	inFlight *flight[T]
+
+	last     T // the last call that worked
+	lastTime time.Time
+	hasLast  bool
+}
+
+type flight[T any] struct {
+	done chan struct{} // closed once val and err are set
+	val  T
+	err  error
+}
+
+// Do calls fn, or if a call is already running, waits for that one and returns what it returned.
+// fn runs with the context of the caller that started it.
+func (sf *SingleFlight[T]) Do(ctx context.Context, fn func(context.Context) (T, error)) (T, error) {
+	sf.lock.Lock()
+	if f := sf.inFlight; f != nil {
+		sf.lock.Unlock()
+		return sf.wait(ctx, f)
+	}
+
+	// if fn panics, waiters get this instead of hanging
+	f := &flight[T]{done: make(chan struct{}), err: errors.New("call in progress panicked")}
+	sf.inFlight = f
+	sf.lock.Unlock()
+
+	defer func() {
+		sf.lock.Lock()
+		sf.inFlight = nil
+		if f.err == nil {
+			sf.last = f.val
+			sf.lastTime = time.Now()
+			sf.hasLast = true
+		}
+		sf.lock.Unlock()
+		close(f.done)
+	}()
+
+	f.val, f.err = fn(ctx)
+	return f.val, f.err
+}
+
+// DoFresh is Do, except a call that's already running may have started before the caller asked,
+// so it waits for that one to finish and then calls fn, or joins a call that started after it asked.
+func (sf *SingleFlight[T]) DoFresh(ctx context.Context, fn func(context.Context) (T, error)) (T, error) {
+	sf.lock.Lock()
+	f := sf.inFlight
+	sf.lock.Unlock()
+
+	if f != nil {
+		var zero T
+		select {
+		case <-f.done:
+		case <-ctx.Done():
+			return zero, ctx.Err()
+		}
+	}
+	return sf.Do(ctx, fn)
+}
+
+// Get returns the last result that worked if it's newer than maxAge, otherwise it's the same as Do.
+// A maxAge of 0 always calls Do.
+func (sf *SingleFlight[T]) Get(ctx context.Context, maxAge time.Duration, fn func(context.Context) (T, error)) (T, error) {
+	if maxAge > 0 {
+		val, when, ok := sf.Last()
+		if ok && time.Since(when) < maxAge {
+			return val, nil
+		}
+	}
+	return sf.Do(ctx, fn)
+}
+
+// Last returns the last result that worked and when it finished, ok is false if nothing has worked yet.
+func (sf *SingleFlight[T]) Last() (val T, when time.Time, ok bool) {
+	sf.lock.Lock()
+	defer sf.lock.Unlock()
+	return sf.last, sf.lastTime, sf.hasLast
+}
+
+func (sf *SingleFlight[T]) wait(ctx context.Context, f *flight[T]) (T, error) {
+	var timeout <-chan time.Time
+	if sf.Timeout > 0 {
+		t := time.NewTimer(sf.Timeout)
+		defer t.Stop()
+		timeout = t.C
+	}
+
+	var zero T
+	select {
+	case <-f.done:
+		return f.val, f.err
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	case <-timeout:
+		return zero, fmt.Errorf("timed out after %v waiting for call in progress", sf.Timeout)
+	}
+}
@@ -0,0 +1,166 @@
+package vmodutils
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"go.viam.com/test"
+)
+
+func TestSingleFlight(t *testing.T) {
+	ctx := context.Background()
+	sf := SingleFlight[int]{}
+
+	_, _, ok := sf.Last()
+	test.That(t, ok, test.ShouldBeFalse)
+
+	var callsLock sync.Mutex
+	calls := 0
+	release := make(chan struct{})
+	fn := func(ctx context.Context) (int, error) {
+		<-release
+		callsLock.Lock()
+		defer callsLock.Unlock()
+		calls++
+		return calls, nil
+	}
+
+	// everyone waits for the first call
+	results := make([]int, 3)
+	wg := sync.WaitGroup{}
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := sf.Do(ctx, fn)
+			test.That(t, err, test.ShouldBeNil)
+			results[i] = v
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	test.That(t, results, test.ShouldResemble, []int{1, 1, 1})
+
+	v, when, ok := sf.Last()
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, v, test.ShouldEqual, 1)
+	test.That(t, time.Since(when), test.ShouldBeLessThan, time.Second)
+
+	// nothing running, so a new call
+	v, err := sf.Do(ctx, fn)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, v, test.ShouldEqual, 2)
+
+	v, err = sf.Get(ctx, time.Minute, fn)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, v, test.ShouldEqual, 2)
+
+	v, err = sf.Get(ctx, 0, fn)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, v, test.ShouldEqual, 3)
+
+	// errors go to everyone waiting, but aren't kept
+	_, err = sf.Do(ctx, func(ctx context.Context) (int, error) { return 0, errors.New("bad") })
+	test.That(t, err, test.ShouldNotBeNil)
+	v, _, _ = sf.Last()
+	test.That(t, v, test.ShouldEqual, 3)
+}
+
+func TestSingleFlightDoFresh(t *testing.T) {
+	ctx := context.Background()
+	sf := SingleFlight[int]{}
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	go sf.Do(ctx, func(ctx context.Context) (int, error) {
+		close(started)
+		<-release
+		return 1, nil
+	})
+	<-started
+
+	fresh := make(chan int)
+	go func() {
+		v, err := sf.DoFresh(ctx, func(ctx context.Context) (int, error) { return 2, nil })
+		test.That(t, err, test.ShouldBeNil)
+		fresh <- v
+	}()
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	test.That(t, <-fresh, test.ShouldEqual, 2)
+
+	// nothing running, same as Do
+	v, err := sf.DoFresh(ctx, func(ctx context.Context) (int, error) { return 3, nil })
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, v, test.ShouldEqual, 3)
+}
+
+func TestSingleFlightWaitTimeout(t *testing.T) {
+	ctx := context.Background()
+	sf := SingleFlight[int]{Timeout: 20 * time.Millisecond}
+
+	release := make(chan struct{})
+	defer close(release)
+	go sf.Do(ctx, func(ctx context.Context) (int, error) {
+		<-release
+		return 1, nil
+	})
+	time.Sleep(10 * time.Millisecond)
+
+	never := func(ctx context.Context) (int, error) {
+		t.Fatal("shouldn't be called while another call is running")
+		return 0, nil
+	}
+
+	_, err := sf.Do(ctx, never)
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "timed out")
+
+	sf.Timeout = 0
+	cctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	_, err = sf.Do(cctx, never)
+	test.That(t, errors.Is(err, context.DeadlineExceeded), test.ShouldBeTrue)
+}
+
+func TestSingleFlightPanic(t *testing.T) {
+	ctx := context.Background()
+	sf := SingleFlight[int]{}
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	panicked := make(chan interface{})
+	go func() {
+		defer func() { panicked <- recover() }()
+		sf.Do(ctx, func(ctx context.Context) (int, error) {
+			close(started)
+			<-release
+			panic("boom")
+		})
+	}()
+	<-started
+
+	waited := make(chan error)
+	go func() {
+		_, err := sf.Do(ctx, func(ctx context.Context) (int, error) { return 0, nil })
+		waited <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+
+	test.That(t, <-panicked, test.ShouldEqual, "boom")
+	err := <-waited
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "panicked")
+
+	// nothing is stuck in flight, and the panic isn't kept as a result
+	_, _, ok := sf.Last()
+	test.That(t, ok, test.ShouldBeFalse)
+	v, err := sf.Do(ctx, func(ctx context.Context) (int, error) { return 5, nil })
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, v, test.ShouldEqual, 5)
+}
@@ -4,14 +4,20 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"math"
+	"slices"
+	"sync"
+	"time"
 
 	"github.com/golang/geo/r3"
 
 	"go.viam.com/rdk/components/arm"
 	toggleswitch "go.viam.com/rdk/components/switch"
 	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/motionplan"
 	"go.viam.com/rdk/referenceframe"
 	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/robot/framesystem"
 	"go.viam.com/rdk/services/motion"
 	"go.viam.com/rdk/spatialmath"
 	"go.viam.com/rdk/utils"
@@ -37,6 +43,137 @@ type ArmPositionSaverConfig struct {
 	Point       r3.Vector
 	Orientation spatialmath.OrientationVectorDegrees
 	Extra       map[string]interface{}
+
+	// if set, go this far (mm) back along the orientation first, then straight in, and leave the same way
+	ApproachDistance         float64 `json:"approach_distance"`
+	LinearToleranceMm        float64 `json:"linear_tolerance_mm"`        // for the straight part, defaults to 1
+	OrientationToleranceDegs float64 `json:"orientation_tolerance_degs"` // for the straight part, defaults to 2
+
+	// how close joints-then-verify-pose has to end up to the saved pose
+	VerifyLinearToleranceMm        float64 `json:"verify_linear_tolerance_mm"`        // defaults to 1
+	VerifyOrientationToleranceDegs float64 `json:"verify_orientation_tolerance_degs"` // defaults to 1
+
+	Persistence *PersistenceConfig `json:"persistence,omitempty"` // where a taught position is saved, defaults to the cloud config
+
+	// used by every motion plan, on top of the frame system
+	Obstacles   []string                `json:"obstacles,omitempty"`   // components with geometries, like obstacle or obstacle-open-box, see armMover.worldState
+	Constraints *motionplan.Constraints `json:"constraints,omitempty"` // linear, orientation, and allowed collisions
+
+	Saved       string          `json:"saved,omitempty"`   // set automatically, when the position was saved
+	Poses       []SavedPosition `json:"poses,omitempty"`   // set automatically, named positions, each is also a switch position
+	History     []HistoryEntry  `json:"history,omitempty"` // set automatically, the positions it replaced or deleted, newest first
+	HistorySize int             `json:"history_size"`      // how many to keep, defaults to 10
+}
+
+// HistoryEntry is a position that was replaced or deleted, Position has a name if it was a named pose.
+type HistoryEntry struct {
+	Saved    string        `json:"saved,omitempty"` // RFC3339, empty if it's from before there was a history
+	Replaced string        `json:"replaced"`        // RFC3339
+	Position SavedPosition `json:"position"`
+}
+
+func (c *ArmPositionSaverConfig) historySize() int {
+	if c.HistorySize <= 0 {
+		return 10
+	}
+	return c.HistorySize
+}
+
+func (c *ArmPositionSaverConfig) approach() *approachConfig {
+	if c.ApproachDistance <= 0 {
+		return nil
+	}
+	a := &approachConfig{distance: c.ApproachDistance, lineTolerance: c.LinearToleranceMm, orientationTolerance: c.OrientationToleranceDegs}
+	if a.lineTolerance <= 0 {
+		a.lineTolerance = 1
+	}
+	if a.orientationTolerance <= 0 {
+		a.orientationTolerance = 2
+	}
+	return a
+}
+
+// position is where the config says to go, without a pose if none was saved.
+func (c *ArmPositionSaverConfig) position() SavedPosition {
+	p := SavedPosition{Joints: c.Joints, Saved: c.Saved}
+	if c.Orientation != (spatialmath.OrientationVectorDegrees{}) {
+		p.Point = &c.Point
+		p.Orientation = &c.Orientation
+	}
+	return p
+}
+
+// fixedPositions is the switch positions before the named poses.
+// leave is always there so named poses keep their switch positions when the approach is turned on or off.
+func (c *ArmPositionSaverConfig) fixedPositions() []string {
+	return []string{"idle", "update config", "go to", "leave"}
+}
+
+// positionNames has one name per switch position, the fixed ones then the named poses.
+func (c *ArmPositionSaverConfig) positionNames() []string {
+	return append(c.fixedPositions(), c.poseNames()...)
+}
+
+func (c *ArmPositionSaverConfig) poseNames() []string {
+	names := []string{}
+	for _, p := range c.Poses {
+		names = append(names, p.Name)
+	}
+	return names
+}
+
+// find returns the position named name, "" is the unnamed one, and its switch position.
+func (c *ArmPositionSaverConfig) find(name string) (SavedPosition, uint32, error) {
+	if name == "" {
+		return c.position(), 2, nil
+	}
+	i := slices.IndexFunc(c.Poses, func(p SavedPosition) bool { return p.Name == name })
+	if i < 0 {
+		return SavedPosition{}, 0, fmt.Errorf("no pose named [%s]", name)
+	}
+	return c.Poses[i], uint32(len(c.fixedPositions()) + i), nil
+}
+
+// atPosition is the position for switch position sp, the unnamed one if sp isn't a named pose.
+func (c *ArmPositionSaverConfig) atPosition(sp uint32) SavedPosition {
+	i := int(sp) - len(c.fixedPositions())
+	if i >= 0 && i < len(c.Poses) {
+		return c.Poses[i]
+	}
+	return c.position()
+}
+
+// strategy is how to go to p, from extra, or the default: motion-plan if there is motion and p has a pose, otherwise joints.
+func (c *ArmPositionSaverConfig) strategy(p SavedPosition, extra map[string]interface{}) (string, error) {
+	s, ok := extra["strategy"]
+	if !ok {
+		if c.Motion != "" && p.hasPose() {
+			return strategyMotionPlan, nil
+		}
+		return strategyJoints, nil
+	}
+
+	switch s {
+	case strategyJoints:
+		return strategyJoints, nil
+	case strategyMotionPlan, strategyJointsThenVerify:
+		if c.Motion == "" {
+			return "", fmt.Errorf("strategy %v needs motion", s)
+		}
+		return s.(string), nil
+	}
+	return "", fmt.Errorf("unknown strategy %v, has to be %s, %s, or %s", s, strategyJoints, strategyMotionPlan, strategyJointsThenVerify)
+}
+
+func (c *ArmPositionSaverConfig) verifyTolerance() PoseError {
+	t := PoseError{LinearMm: c.VerifyLinearToleranceMm, OrientationDegs: c.VerifyOrientationToleranceDegs}
+	if t.LinearMm <= 0 {
+		t.LinearMm = 1
+	}
+	if t.OrientationDegs <= 0 {
+		t.OrientationDegs = 1
+	}
+	return t
 }
 
 func (c *ArmPositionSaverConfig) Validate(path string) ([]string, []string, error) {
@@ -44,6 +181,37 @@ func (c *ArmPositionSaverConfig) Validate(path string) ([]string, []string, erro
 		return nil, nil, fmt.Errorf("no arm specificed")
 	}
 
+	if c.ApproachDistance < 0 || c.LinearToleranceMm < 0 || c.OrientationToleranceDegs < 0 {
+		return nil, nil, fmt.Errorf("approach_distance and tolerances can't be negative")
+	}
+	if c.ApproachDistance > 0 && c.Motion == "" {
+		return nil, nil, fmt.Errorf("approach_distance needs motion")
+	}
+	if c.VerifyLinearToleranceMm < 0 || c.VerifyOrientationToleranceDegs < 0 {
+		return nil, nil, fmt.Errorf("verify tolerances can't be negative")
+	}
+	if c.HistorySize < 0 {
+		return nil, nil, fmt.Errorf("history_size can't be negative")
+	}
+	if _, err := c.strategy(c.position(), c.Extra); err != nil {
+		return nil, nil, err
+	}
+	for i, p := range c.Poses {
+		if p.Name == "" {
+			return nil, nil, fmt.Errorf("pose %d needs a name", i)
+		}
+		if slices.ContainsFunc(c.Poses[:i], func(x SavedPosition) bool { return x.Name == p.Name }) {
+			return nil, nil, fmt.Errorf("more than one pose named %s", p.Name)
+		}
+	}
+	if err := c.Persistence.Validate(); err != nil {
+		return nil, nil, err
+	}
+
+	if (len(c.Obstacles) > 0 || c.Constraints != nil) && c.Motion == "" {
+		return nil, nil, fmt.Errorf("obstacles and constraints need motion")
+	}
+
 	deps := []string{c.Arm}
 
 	if c.Motion != "" {
@@ -54,15 +222,51 @@ func (c *ArmPositionSaverConfig) Validate(path string) ([]string, []string, erro
 		}
 	}
 
+	if len(c.Obstacles) > 0 {
+		deps = append(deps, c.Obstacles...)
+		deps = append(deps, framesystem.PublicServiceName.String())
+	}
+
 	return deps, nil, nil
 }
 
+// moveArgs splits the configured extra plus extra into the joint move options, the strategy for p, and what's passed on.
+// Speed limits asked for with the move are an error if the strategy won't move the joints, instead of being dropped.
+func (c *ArmPositionSaverConfig) moveArgs(p SavedPosition, extra map[string]interface{}) (*arm.MoveOptions, string, map[string]interface{}, error) {
+	asked, _ := moveOptionsFromExtra(extra)
+	opts, extra := moveOptionsFromExtra(c.Extra, extra)
+
+	strategy, err := c.strategy(p, extra)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	if asked != nil && strategy == strategyMotionPlan {
+		return nil, "", nil, fmt.Errorf("max_vel_degs_per_sec and max_acc_degs_per_sec2 only limit joint moves, not %s", strategy)
+	}
+	delete(extra, "strategy")
+	if len(extra) == 0 {
+		extra = nil
+	}
+
+	return opts, strategy, extra, nil
+}
+
 func newArmPositionSaver(ctx context.Context, deps resource.Dependencies, config resource.Config, logger logging.Logger) (toggleswitch.Switch, error) {
 	newConf, err := resource.NativeConfig[*ArmPositionSaverConfig](config)
 	if err != nil {
 		return nil, err
 	}
 
+	store, err := newConf.Persistence.NewAttributeStore(config, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	newConf, err = loadSavedConfig[*ArmPositionSaverConfig](ctx, store, config.Attributes)
+	if err != nil {
+		return nil, err
+	}
+
 	arm, err := arm.FromProvider(deps, newConf.Arm)
 	if err != nil {
 		return nil, err
@@ -71,6 +275,7 @@ func newArmPositionSaver(ctx context.Context, deps resource.Dependencies, config
 	aps := &ArmPositionSaver{
 		name:   config.ResourceName(),
 		cfg:    newConf,
+		store:  store,
 		logger: logger,
 		arm:    arm,
 	}
@@ -82,39 +287,154 @@ func newArmPositionSaver(ctx context.Context, deps resource.Dependencies, config
 		}
 	}
 
+	aps.mover = &armMover{saver: aps.name, armName: newConf.Arm, arm: arm, motion: aps.motion, constraints: newConf.Constraints, logger: logger}
+
+	for _, n := range newConf.Obstacles {
+		r, ok := vmodutils.FindDep(deps, n)
+		if !ok {
+			return nil, fmt.Errorf("cannot find obstacle [%s]", n)
+		}
+		o, ok := r.(resource.Shaped)
+		if !ok {
+			return nil, fmt.Errorf("obstacle [%s] has no geometries", n)
+		}
+		aps.mover.obstacles = append(aps.mover.obstacles, armObstacle{name: n, shaped: o})
+	}
+
+	if len(newConf.Obstacles) > 0 {
+		aps.mover.fs, err = framesystem.FromDependencies(deps)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return aps, nil
 }
 
 type ArmPositionSaver struct {
 	resource.AlwaysRebuild
-	resource.TriviallyCloseable
 
 	name   resource.Name
-	cfg    *ArmPositionSaverConfig
+	store  AttributeStore
 	logger logging.Logger
 
 	arm    arm.Arm
 	motion motion.Service
+	mover  *armMover
+
+	cfgLock sync.Mutex
+	cfg     *ArmPositionSaverConfig // replaced, not modified, when a position is saved
+	current uint32                  // the switch position last gone to, 0 if none since the positions changed
 }
 
 func (aps *ArmPositionSaver) Name() resource.Name {
 	return aps.name
 }
 
+func (aps *ArmPositionSaver) Close(ctx context.Context) error {
+	aps.mover.forgetEntered()
+	return nil
+}
+
+func (aps *ArmPositionSaver) config() *ArmPositionSaverConfig {
+	aps.cfgLock.Lock()
+	defer aps.cfgLock.Unlock()
+	return aps.cfg
+}
+
+// DoCommand
+//
+//	{"cfg" : true} - the saved position
+//	{"go_to" : true} or {"go_to" : {<extra>}} - same as position 2, also returns the strategy used,
+//	                                            and how far from the saved pose the arm ended up if it was verified
+//	{"go_to" : "<name>"} - the same for a named pose
+//	{"plan" : true} or {"plan" : {<extra>}} or {"plan" : "<name>"} - plans the motion-plan move without moving, and returns the plan
+//	{"list" : true} - the named poses
+//	{"save" : "<name>"} - saves where the arm is now as a named pose, replacing the pose with that name if there is one
+//	{"rename" : {"from" : "<name>", "to" : "<name>"}}
+//	{"delete" : "<name>"} - the pose goes in the history, so it can be restored
+//	{"history" : true} - the positions that were replaced or deleted, newest first
+//	{"restore" : <index>} - saves that history entry as the position, or as the named pose it was
 func (aps *ArmPositionSaver) DoCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
 	if cmd["cfg"] == true {
-		jsonData, err := json.Marshal(aps.cfg)
+		cfg := aps.config()
+		jsonData, err := json.Marshal(cfg)
 		if err != nil {
 			return nil, err
 		}
 
 		return map[string]interface{}{
-			"joints":      aps.cfg.Joints,
-			"point":       aps.cfg.Point,
-			"orientation": aps.cfg.Orientation,
+			"joints":      cfg.Joints,
+			"point":       cfg.Point,
+			"orientation": cfg.Orientation,
 			"as_json":     string(jsonData),
 		}, nil
 	}
+
+	if goTo, ok := cmd["go_to"]; ok {
+		extra, _ := goTo.(map[string]interface{})
+		name, _ := goTo.(string)
+		strategy, pe, err := aps.goToSavePosition(ctx, name, extra)
+		if err != nil {
+			return nil, err
+		}
+		res := map[string]interface{}{"strategy": strategy}
+		if pe != nil {
+			res["linear_error_mm"] = pe.LinearMm
+			res["orientation_error_degs"] = pe.OrientationDegs
+		}
+		return res, nil
+	}
+
+	if cmd["list"] == true {
+		cfg := aps.config()
+		return map[string]interface{}{"names": cfg.poseNames(), "poses": cfg.Poses}, nil
+	}
+
+	if name, ok := cmd["save"].(string); ok {
+		return nil, aps.savePose(ctx, name)
+	}
+
+	if r, ok := cmd["rename"].(map[string]interface{}); ok {
+		from, _ := r["from"].(string)
+		to, _ := r["to"].(string)
+		return nil, aps.renamePose(ctx, from, to)
+	}
+
+	if name, ok := cmd["delete"].(string); ok {
+		return nil, aps.deletePose(ctx, name)
+	}
+
+	if cmd["history"] == true {
+		history := []interface{}{}
+		for _, h := range aps.config().History {
+			m, err := vmodutils.ToAttributeMap(h)
+			if err != nil {
+				return nil, err
+			}
+			history = append(history, map[string]interface{}(m))
+		}
+		return map[string]interface{}{"history": history}, nil
+	}
+
+	if r, ok := cmd["restore"]; ok {
+		i, err := restoreIndex(r)
+		if err != nil {
+			return nil, err
+		}
+		return nil, aps.restore(ctx, i)
+	}
+
+	if p, ok := cmd["plan"]; ok {
+		extra, _ := p.(map[string]interface{})
+		name, _ := p.(string)
+		plan, err := aps.planSavePosition(ctx, name, extra)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"plan": plan}, nil
+	}
+
 	return nil, fmt.Errorf("unknown command %v", cmd)
 }
 
@@ -128,87 +448,787 @@ func (aps *ArmPositionSaver) SetPosition(ctx context.Context, position uint32, e
 	}
 
 	if position == 2 {
-		return aps.goToSavePosition(ctx)
+		_, _, err := aps.goToSavePosition(ctx, "", extra)
+		return err
+	}
+
+	cfg := aps.config()
+
+	if position == 3 {
+		if cfg.approach() == nil {
+			return fmt.Errorf("leave needs approach_distance")
+		}
+		return aps.leaveSavePosition(ctx, extra)
+	}
+
+	names := cfg.positionNames()
+	if int(position) >= len(cfg.fixedPositions()) && int(position) < len(names) {
+		_, _, err := aps.goToSavePosition(ctx, names[position], extra)
+		return err
 	}
 
 	return fmt.Errorf("bad position: %d", position)
 }
 
+// GetPosition is the last position gone to, 2 for the saved position or a named pose's position,
+// or 0 if there hasn't been one since the positions changed.
 func (aps *ArmPositionSaver) GetPosition(ctx context.Context, extra map[string]interface{}) (uint32, error) {
-	return 0, nil
+	aps.cfgLock.Lock()
+	defer aps.cfgLock.Unlock()
+	return aps.current, nil
 }
 
 func (aps *ArmPositionSaver) GetNumberOfPositions(ctx context.Context, extra map[string]interface{}) (uint32, []string, error) {
-	return 3, []string{"idle", "update config", "go to"}, nil
+	names := aps.config().positionNames()
+	return uint32(len(names)), names, nil
 }
 
+// saveCurrentPosition saves the joints, and the world pose if there is motion.
 func (aps *ArmPositionSaver) saveCurrentPosition(ctx context.Context) error {
-	newConfig := utils.AttributeMap{
-		"arm":    aps.cfg.Arm,
-		"motion": aps.cfg.Motion,
+	return aps.savePose(ctx, "")
+}
+
+// savePose saves where the arm is now as the pose named name, "" is the unnamed position.
+func (aps *ArmPositionSaver) savePose(ctx context.Context, name string) error {
+	aps.cfgLock.Lock()
+	defer aps.cfgLock.Unlock()
+
+	p, err := aps.mover.current(ctx)
+	if err != nil {
+		return err
 	}
+	p.Name = name
+	p.Saved = time.Now().Format(time.RFC3339Nano)
 
-	if aps.cfg.Motion == "" {
-		inputs, err := aps.arm.JointPositions(ctx, nil)
-		if err != nil {
-			return err
+	return aps.save(ctx, p, aps.cfg.History)
+}
+
+func (aps *ArmPositionSaver) renamePose(ctx context.Context, from, to string) error {
+	aps.cfgLock.Lock()
+	defer aps.cfgLock.Unlock()
+
+	if to == "" {
+		return fmt.Errorf("need a new name")
+	}
+	i := slices.IndexFunc(aps.cfg.Poses, func(p SavedPosition) bool { return p.Name == from })
+	if i < 0 {
+		return fmt.Errorf("no pose named [%s]", from)
+	}
+	if slices.ContainsFunc(aps.cfg.Poses, func(p SavedPosition) bool { return p.Name == to }) {
+		return fmt.Errorf("already have a pose named [%s]", to)
+	}
+
+	cfg := *aps.cfg
+	cfg.Poses = slices.Clone(cfg.Poses)
+	cfg.Poses[i].Name = to
+	return aps.update(ctx, &cfg)
+}
+
+// deletePose removes the pose named name, it goes in the history so it can be restored.
+func (aps *ArmPositionSaver) deletePose(ctx context.Context, name string) error {
+	aps.cfgLock.Lock()
+	defer aps.cfgLock.Unlock()
+
+	i := slices.IndexFunc(aps.cfg.Poses, func(p SavedPosition) bool { return p.Name == name })
+	if i < 0 {
+		return fmt.Errorf("no pose named [%s]", name)
+	}
+
+	cfg := *aps.cfg
+	cfg.Poses = slices.Delete(slices.Clone(cfg.Poses), i, i+1)
+	cfg.History = cfg.withHistory(aps.cfg.Poses[i], cfg.History)
+	return aps.update(ctx, &cfg)
+}
+
+// restoreIndex is the history entry a restore is for. It has to be a whole number,
+// from json it's a float64, a go caller can pass an int.
+func restoreIndex(v interface{}) (int, error) {
+	switch x := v.(type) {
+	case int:
+		return x, nil
+	case int32:
+		return int(x), nil
+	case int64:
+		return int(x), nil
+	case float64:
+		if x == math.Trunc(x) {
+			return int(x), nil
+		}
+	}
+	return 0, fmt.Errorf("restore index has to be a whole number, not %v", v)
+}
+
+// restore saves history entry i as the position, or the named pose it was,
+// the position it replaces goes in the history, so it can be restored too.
+func (aps *ArmPositionSaver) restore(ctx context.Context, i int) error {
+	aps.cfgLock.Lock()
+	defer aps.cfgLock.Unlock()
+
+	if i < 0 || i >= len(aps.cfg.History) {
+		return fmt.Errorf("no history entry %d, there are %d", i, len(aps.cfg.History))
+	}
+
+	// it keeps the time it was first saved
+	p := aps.cfg.History[i].Position
+	p.Saved = aps.cfg.History[i].Saved
+
+	history := slices.Delete(slices.Clone(aps.cfg.History), i, i+1)
+	return aps.save(ctx, p, history)
+}
+
+// save replaces the position with p's name with p, and puts the old one at the front of history. cfgLock has to be held.
+func (aps *ArmPositionSaver) save(ctx context.Context, p SavedPosition, history []HistoryEntry) error {
+	cfg := *aps.cfg
+
+	if p.Name == "" {
+		old := cfg.position()
+		if len(old.Joints) > 0 || old.hasPose() {
+			history = cfg.withHistory(old, history)
 		}
 
-		newConfig["joints"] = inputs
+		cfg.Joints = p.Joints
+		cfg.Point = r3.Vector{}
+		cfg.Orientation = spatialmath.OrientationVectorDegrees{}
+		if p.hasPose() {
+			cfg.Point = *p.Point
+			cfg.Orientation = *p.Orientation
+		}
+		cfg.Saved = p.Saved
 	} else {
-		p, err := aps.motion.GetPose(ctx, aps.cfg.Arm, "world", nil, nil)
+		cfg.Poses = slices.Clone(cfg.Poses)
+		i := slices.IndexFunc(cfg.Poses, func(x SavedPosition) bool { return x.Name == p.Name })
+		if i < 0 {
+			cfg.Poses = append(cfg.Poses, p)
+		} else {
+			history = cfg.withHistory(cfg.Poses[i], history)
+			cfg.Poses[i] = p
+		}
+	}
+	cfg.History = history
+
+	return aps.update(ctx, &cfg)
+}
+
+// withHistory puts old at the front of history, keeping at most history_size entries.
+func (c *ArmPositionSaverConfig) withHistory(old SavedPosition, history []HistoryEntry) []HistoryEntry {
+	e := HistoryEntry{Saved: old.Saved, Replaced: time.Now().Format(time.RFC3339Nano), Position: old}
+	e.Position.Saved = ""
+
+	history = append([]HistoryEntry{e}, history...)
+	if len(history) > c.historySize() {
+		history = history[:c.historySize()]
+	}
+	return history
+}
+
+// update saves cfg's positions and uses it. cfgLock has to be held.
+func (aps *ArmPositionSaver) update(ctx context.Context, cfg *ArmPositionSaverConfig) error {
+	values := utils.AttributeMap{
+		"joints":      cfg.Joints,
+		"point":       nil,
+		"orientation": nil,
+		"saved":       cfg.Saved,
+		"poses":       cfg.Poses,
+		"history":     cfg.History,
+	}
+	if cfg.position().hasPose() {
+		values["point"] = cfg.Point
+		values["orientation"] = cfg.Orientation
+	}
+
+	err := aps.store.Save(ctx, values)
+	if err != nil {
+		return err
+	}
+	aps.cfg = cfg
+	aps.current = 0
+	return nil
+}
+
+// goToSavePosition moves to the position named name, "" is the unnamed one, with the strategy in extra,
+// see ArmPositionSaverConfig.strategy. extra is added to the configured extra, see moveOptionsFromExtra.
+// If the strategy verifies the pose, it returns how far off the arm is.
+func (aps *ArmPositionSaver) goToSavePosition(ctx context.Context, name string, extra map[string]interface{}) (string, *PoseError, error) {
+	cfg := aps.config()
+	p, sp, err := cfg.find(name)
+	if err != nil {
+		return "", nil, err
+	}
+
+	strategy, pe, err := aps.goTo(ctx, cfg, p, extra)
+	if err != nil {
+		return strategy, pe, err
+	}
+
+	aps.cfgLock.Lock()
+	if aps.cfg == cfg {
+		aps.current = sp
+	}
+	aps.cfgLock.Unlock()
+	return strategy, pe, nil
+}
+
+func (aps *ArmPositionSaver) goTo(ctx context.Context, cfg *ArmPositionSaverConfig, p SavedPosition, extra map[string]interface{}) (string, *PoseError, error) {
+	opts, strategy, extra, err := cfg.moveArgs(p, extra)
+	if err != nil {
+		return "", nil, err
+	}
+
+	err = aps.mover.backOut(ctx, p, extra)
+	if err != nil {
+		return strategy, nil, err
+	}
+
+	switch strategy {
+	case strategyMotionPlan:
+		if a := cfg.approach(); a != nil {
+			return strategy, nil, aps.mover.goToWithApproach(ctx, p, a, extra)
+		}
+		return strategy, nil, aps.mover.goToPose(ctx, p, extra)
+	case strategyJointsThenVerify:
+		err := aps.mover.goToJoints(ctx, p, opts, extra)
 		if err != nil {
-			return err
+			return strategy, nil, err
+		}
+		pe, err := aps.mover.verify(ctx, p, cfg.verifyTolerance())
+		return strategy, pe, err
+	default:
+		return strategy, nil, aps.mover.goToJoints(ctx, p, opts, extra)
+	}
+}
+
+// planSavePosition plans the motion-plan move to the position named name, "" is the unnamed one, without moving,
+// and returns the plan. With an approach, it plans to the approach point, since the straight part starts there.
+func (aps *ArmPositionSaver) planSavePosition(ctx context.Context, name string, extra map[string]interface{}) (interface{}, error) {
+	cfg := aps.config()
+	p, _, err := cfg.find(name)
+	if err != nil {
+		return nil, err
+	}
+
+	_, _, extra, err = cfg.moveArgs(p, extra)
+	if err != nil {
+		return nil, err
+	}
+
+	if !p.hasPose() {
+		return nil, fmt.Errorf("no saved pose")
+	}
+
+	pose := p.pose()
+	if a := cfg.approach(); a != nil {
+		pose, err = a.approachPose(p)
+		if err != nil {
+			return nil, err
 		}
-		newConfig["point"] = p.Pose().Point()
-		newConfig["orientation"] = p.Pose().Orientation().OrientationVectorDegrees()
 	}
 
-	return vmodutils.UpdateComponentCloudAttributesFromModuleEnv(ctx, aps.name, newConfig, aps.logger)
+	return aps.mover.plan(ctx, pose, nil, extra)
 }
 
-func (aps *ArmPositionSaver) goToSavePosition(ctx context.Context) error {
-	if len(aps.cfg.Joints) > 0 {
-		return aps.arm.MoveToJointPositions(ctx, aps.cfg.Joints, aps.cfg.Extra)
+// leaveSavePosition backs straight out to the approach point of the position the arm last went into,
+// or of the position last gone to if there's no record of that. If the arm isn't inside either, it doesn't move.
+func (aps *ArmPositionSaver) leaveSavePosition(ctx context.Context, extra map[string]interface{}) error {
+	aps.cfgLock.Lock()
+	cfg, current := aps.cfg, aps.current
+	aps.cfgLock.Unlock()
+
+	p, a := cfg.atPosition(current), cfg.approach()
+	if e := aps.mover.lastEntered(); e != nil {
+		p, a = e.p, &e.a
+	} else if current == 0 {
+		aps.mover.logger.Debugf("leave: no position gone to, nothing to back out of")
+		return nil
 	}
 
-	if aps.motion != nil {
-		current, err := aps.motion.GetPose(ctx, aps.cfg.Arm, "world", nil, nil)
+	inside, err := aps.mover.inside(ctx, p, a)
+	if err != nil {
+		return err
+	}
+	if inside {
+		_, extra = moveOptionsFromExtra(cfg.Extra, extra)
+		err = aps.mover.leave(ctx, p, a, extra)
 		if err != nil {
 			return err
 		}
+	} else {
+		aps.mover.logger.Debugf("leave: arm isn't inside the position, nothing to back out of")
+		aps.mover.setEntered(nil)
+	}
 
-		linearDelta := current.Pose().Point().Distance(aps.cfg.Point)
-		orientationDelta := spatialmath.QuatToR3AA(spatialmath.OrientationBetween(current.Pose().Orientation(), &aps.cfg.Orientation).Quaternion()).Norm2()
+	aps.cfgLock.Lock()
+	if aps.cfg == cfg {
+		aps.current = 0
+	}
+	aps.cfgLock.Unlock()
+	return nil
+}
 
-		aps.logger.Debugf("goToSavePosition linearDelta: %v orientationDelta: %v", linearDelta, orientationDelta)
-		if linearDelta < .1 && orientationDelta < .01 {
-			aps.logger.Debugf("close enough, not moving - linearDelta: %v orientationDelta: %v", linearDelta, orientationDelta)
-			return nil
+// moveOptionsFromExtra merges extras, later ones win, and takes out max_vel_degs_per_sec and max_acc_degs_per_sec2
+// as arm move options, which are nil if neither is set.
+func moveOptionsFromExtra(extras ...map[string]interface{}) (*arm.MoveOptions, map[string]interface{}) {
+	merged := map[string]interface{}{}
+	for _, e := range extras {
+		for k, v := range e {
+			merged[k] = v
 		}
+	}
+
+	vel, hasVel := merged["max_vel_degs_per_sec"].(float64)
+	acc, hasAcc := merged["max_acc_degs_per_sec2"].(float64)
+	delete(merged, "max_vel_degs_per_sec")
+	delete(merged, "max_acc_degs_per_sec2")
 
-		pif := referenceframe.NewPoseInFrame(
-			"world",
-			spatialmath.NewPose(aps.cfg.Point, &aps.cfg.Orientation),
-		)
+	if len(merged) == 0 {
+		merged = nil
+	}
+
+	if !hasVel && !hasAcc {
+		return nil, merged
+	}
+	return &arm.MoveOptions{MaxVelRads: utils.DegToRad(vel), MaxAccRads: utils.DegToRad(acc)}, merged
+}
+
+// SavedPosition is where an arm was, as joints, a world pose, or both.
+type SavedPosition struct {
+	Name        string                                `json:"name,omitempty"`  // only for named poses
+	Saved       string                                `json:"saved,omitempty"` // RFC3339, when it was saved
+	Joints      []float64                             `json:"joints,omitempty"`
+	Point       *r3.Vector                            `json:"point,omitempty"`
+	Orientation *spatialmath.OrientationVectorDegrees `json:"orientation,omitempty"`
+}
 
-		done, err := aps.motion.Move(
-			ctx,
-			motion.MoveReq{
-				ComponentName: aps.cfg.Arm,
-				Destination:   pif,
-				WorldState:    nil,
-				Extra:         aps.cfg.Extra,
-			},
-		)
+func (p SavedPosition) hasPose() bool {
+	return p.Point != nil && p.Orientation != nil
+}
+
+func (p SavedPosition) pose() spatialmath.Pose {
+	return spatialmath.NewPose(*p.Point, p.Orientation)
+}
+
+// ways to go to a SavedPosition, picked with "strategy" in extra
+const (
+	strategyJoints           = "joints"                  // move the joints
+	strategyMotionPlan       = "motion-plan"             // plan to the world pose with motion
+	strategyJointsThenVerify = "joints-then-verify-pose" // move the joints, then check the world pose is where it was saved
+)
+
+// PoseError is how far apart two poses are.
+type PoseError struct {
+	LinearMm        float64
+	OrientationDegs float64
+}
+
+func poseError(a, b spatialmath.Pose) PoseError {
+	return PoseError{
+		LinearMm:        a.Point().Distance(b.Point()),
+		OrientationDegs: utils.RadToDeg(spatialmath.QuatToR3AA(spatialmath.OrientationBetween(a.Orientation(), b.Orientation()).Quaternion()).Norm2()),
+	}
+}
+
+func (e PoseError) within(tolerance PoseError) bool {
+	return e.LinearMm <= tolerance.LinearMm && e.OrientationDegs <= tolerance.OrientationDegs
+}
+
+// armMover moves an arm to a SavedPosition, with joints if it has them, otherwise with motion.
+type armMover struct {
+	saver   resource.Name // the arm-position-saver this moves for
+	armName string
+	arm     arm.Arm
+	motion  motion.Service // nil if not configured
+	logger  logging.Logger
+
+	// for every motion plan
+	obstacles   []armObstacle
+	fs          framesystem.Service // to find which obstacles have a frame, nil if there are no obstacles
+	constraints *motionplan.Constraints
+}
+
+// armObstacle is a component with geometries to plan around.
+type armObstacle struct {
+	name   string
+	shaped resource.Shaped
+}
+
+// current is where the arm is now, joints always, and the world pose if there is a motion service.
+func (m *armMover) current(ctx context.Context) (SavedPosition, error) {
+	inputs, err := m.arm.JointPositions(ctx, nil)
+	if err != nil {
+		return SavedPosition{}, err
+	}
+
+	p := SavedPosition{Joints: inputs}
+
+	if m.motion != nil {
+		pif, err := m.motion.GetPose(ctx, m.armName, "world", nil, nil)
 		if err != nil {
-			return err
+			return SavedPosition{}, err
+		}
+		pt := pif.Pose().Point()
+		p.Point = &pt
+		p.Orientation = pif.Pose().Orientation().OrientationVectorDegrees()
+	}
+
+	return p, nil
+}
+
+// goTo moves to p, with joints if it has them, otherwise with motion. opts is only used when moving with joints, and can be nil.
+func (m *armMover) goTo(ctx context.Context, p SavedPosition, opts *arm.MoveOptions, extra map[string]interface{}) error {
+	if len(p.Joints) > 0 {
+		return m.goToJoints(ctx, p, opts, extra)
+	}
+
+	if m.motion != nil && p.hasPose() {
+		return m.goToPose(ctx, p, extra)
+	}
+
+	return fmt.Errorf("need to configure where to go")
+}
+
+// goToJoints moves the arm to p's joints, opts can be nil.
+func (m *armMover) goToJoints(ctx context.Context, p SavedPosition, opts *arm.MoveOptions, extra map[string]interface{}) error {
+	if len(p.Joints) == 0 {
+		return fmt.Errorf("no saved joints")
+	}
+	defer m.stopOnCancel(ctx)()
+
+	if opts != nil {
+		return m.arm.MoveThroughJointPositions(ctx, [][]referenceframe.Input{p.Joints}, opts, extra)
+	}
+	return m.arm.MoveToJointPositions(ctx, p.Joints, extra)
+}
+
+// goToPose moves the arm to p's world pose with motion.
+func (m *armMover) goToPose(ctx context.Context, p SavedPosition, extra map[string]interface{}) error {
+	if m.motion == nil {
+		return fmt.Errorf("moving to a pose needs motion")
+	}
+	if !p.hasPose() {
+		return fmt.Errorf("no saved pose")
+	}
+	return m.moveTo(ctx, p.pose(), nil, extra)
+}
+
+// verify returns how far the arm is from p's world pose, and an error if that's more than tolerance.
+func (m *armMover) verify(ctx context.Context, p SavedPosition, tolerance PoseError) (*PoseError, error) {
+	if m.motion == nil {
+		return nil, fmt.Errorf("verifying a pose needs motion")
+	}
+	if !p.hasPose() {
+		return nil, fmt.Errorf("no saved pose to verify")
+	}
+
+	current, err := m.motion.GetPose(ctx, m.armName, "world", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	pe := poseError(current.Pose(), p.pose())
+	m.logger.Debugf("verify linear error: %v mm orientation error: %v degs", pe.LinearMm, pe.OrientationDegs)
+	if !pe.within(tolerance) {
+		return &pe, fmt.Errorf("arm is %0.2f mm and %0.2f degs from the saved pose, tolerance is %0.2f mm and %0.2f degs",
+			pe.LinearMm, pe.OrientationDegs, tolerance.LinearMm, tolerance.OrientationDegs)
+	}
+	return &pe, nil
+}
+
+// stopOnCancel stops the arm if ctx is canceled before the returned func is called,
+// since not every arm stops on its own when the context of a move goes away.
+func (m *armMover) stopOnCancel(ctx context.Context) func() {
+	if m.arm == nil {
+		return func() {}
+	}
+	stop := context.AfterFunc(ctx, func() {
+		err := m.arm.Stop(context.Background(), nil)
+		if err != nil {
+			m.logger.Warnf("couldn't stop arm after cancel: %v", err)
 		}
-		if !done {
-			return fmt.Errorf("move didn't finish")
+	})
+	return func() { stop() }
+}
+
+// approachConfig is how to get into and out of a position in a straight line.
+type approachConfig struct {
+	distance             float64
+	lineTolerance        float64
+	orientationTolerance float64
+}
+
+func (a *approachConfig) constraints() *motionplan.Constraints {
+	return &motionplan.Constraints{
+		LinearConstraint: []motionplan.LinearConstraint{
+			{LineToleranceMm: a.lineTolerance, OrientationToleranceDegs: a.orientationTolerance},
+		},
+	}
+}
+
+// approachPose is a.distance back from p along its orientation.
+func (a *approachConfig) approachPose(p SavedPosition) (spatialmath.Pose, error) {
+	if !p.hasPose() {
+		return nil, fmt.Errorf("an approach needs a saved pose")
+	}
+	return spatialmath.NewPose(GetApproachPoint(*p.Point, a.distance, p.Orientation), p.Orientation), nil
+}
+
+// enteredApproach is a position an arm went straight into, it has to back out the same way before going anywhere else.
+// It only has the position and approach, so whichever saver is current backs out with its own arm and motion.
+type enteredApproach struct {
+	saver resource.Name // that went in
+	p     SavedPosition
+	a     approachConfig
+}
+
+// entered is, by arm name, the position each arm last went straight into, shared by every arm-position-saver in the module.
+var (
+	enteredLock sync.Mutex
+	entered     = map[string]*enteredApproach{}
+)
+
+// setEntered records the position the arm went straight into, nil if it's out.
+func (m *armMover) setEntered(e *enteredApproach) {
+	enteredLock.Lock()
+	defer enteredLock.Unlock()
+	if e == nil {
+		delete(entered, m.armName)
+		return
+	}
+	entered[m.armName] = e
+}
+
+// lastEntered is the position the arm last went straight into, or nil if it's backed out since.
+func (m *armMover) lastEntered() *enteredApproach {
+	enteredLock.Lock()
+	defer enteredLock.Unlock()
+	return entered[m.armName]
+}
+
+// forgetEntered forgets the position the arm went into if this saver went into it,
+// so nothing is left from a saver that's gone.
+func (m *armMover) forgetEntered() {
+	enteredLock.Lock()
+	defer enteredLock.Unlock()
+	if e, ok := entered[m.armName]; ok && e.saver == m.saver {
+		delete(entered, m.armName)
+	}
+}
+
+// backOut backs straight out of the position the arm last went into with an approach, unless that's p,
+// or the arm has moved away from it since.
+func (m *armMover) backOut(ctx context.Context, p SavedPosition, extra map[string]interface{}) error {
+	e := m.lastEntered()
+	if e == nil {
+		return nil
+	}
+	if p.hasPose() && poseError(p.pose(), e.p.pose()).within(closeEnough) {
+		return nil
+	}
+
+	inside, err := m.inside(ctx, e.p, &e.a)
+	if err != nil {
+		return err
+	}
+	if inside {
+		err = m.leave(ctx, e.p, &e.a, extra)
+		if err != nil {
+			return fmt.Errorf("cannot back out of the last position: %w", err)
 		}
+	}
+
+	m.setEntered(nil)
+	return nil
+}
+
+// inside returns true if the arm is between p and its approach point, where it can only move in a straight line.
+func (m *armMover) inside(ctx context.Context, p SavedPosition, a *approachConfig) (bool, error) {
+	current, err := m.motion.GetPose(ctx, m.armName, "world", nil, nil)
+	if err != nil {
+		return false, err
+	}
+	return current.Pose().Point().Distance(*p.Point) < a.distance+a.lineTolerance, nil
+}
+
+// goToWithApproach moves to the approach point, then straight to p. If already at p, it doesn't move.
+// If it's near p, it backs straight out to the approach point first, instead of moving there freely.
+func (m *armMover) goToWithApproach(ctx context.Context, p SavedPosition, a *approachConfig, extra map[string]interface{}) error {
+	if m.motion == nil {
+		return fmt.Errorf("an approach needs motion")
+	}
+
+	approach, err := a.approachPose(p)
+	if err != nil {
+		return err
+	}
+	final := p.pose()
+
+	there, err := m.closeTo(ctx, final)
+	if err != nil {
+		return err
+	}
+	if there {
+		m.setEntered(&enteredApproach{saver: m.saver, p: p, a: *a})
 		return nil
 	}
 
-	return fmt.Errorf("need to configure where to go")
+	inside, err := m.inside(ctx, p, a)
+	if err != nil {
+		return err
+	}
+	var constraints *motionplan.Constraints
+	if inside {
+		constraints = a.constraints()
+	}
+
+	err = m.moveTo(ctx, approach, constraints, extra)
+	if err != nil {
+		return fmt.Errorf("cannot get to approach point: %w", err)
+	}
+
+	err = m.moveTo(ctx, final, a.constraints(), extra)
+	if err != nil {
+		return err
+	}
+	m.setEntered(&enteredApproach{saver: m.saver, p: p, a: *a})
+	return nil
+}
+
+// leave moves straight back from p to its approach point.
+func (m *armMover) leave(ctx context.Context, p SavedPosition, a *approachConfig, extra map[string]interface{}) error {
+	if m.motion == nil {
+		return fmt.Errorf("an approach needs motion")
+	}
+
+	approach, err := a.approachPose(p)
+	if err != nil {
+		return err
+	}
+
+	err = m.moveTo(ctx, approach, a.constraints(), extra)
+	if err != nil {
+		return err
+	}
+	m.setEntered(nil)
+	return nil
+}
+
+// closeEnough is how close the arm has to be to a pose to not move.
+var closeEnough = PoseError{LinearMm: .1, OrientationDegs: utils.RadToDeg(.01)}
+
+// closeTo returns true if the arm is already at pose.
+func (m *armMover) closeTo(ctx context.Context, pose spatialmath.Pose) (bool, error) {
+	current, err := m.motion.GetPose(ctx, m.armName, "world", nil, nil)
+	if err != nil {
+		return false, err
+	}
+
+	pe := poseError(current.Pose(), pose)
+
+	m.logger.Debugf("goToSavePosition linearDelta: %v orientationDelta: %v", pe.LinearMm, pe.OrientationDegs)
+	if pe.within(closeEnough) {
+		m.logger.Debugf("close enough, not moving - linearDelta: %v orientationDelta: %v", pe.LinearMm, pe.OrientationDegs)
+		return true, nil
+	}
+	return false, nil
+}
+
+// moveTo moves the arm to pose in world with motion, unless it's already there.
+func (m *armMover) moveTo(ctx context.Context, pose spatialmath.Pose, constraints *motionplan.Constraints, extra map[string]interface{}) error {
+	there, err := m.closeTo(ctx, pose)
+	if err != nil || there {
+		return err
+	}
+
+	req, err := m.moveReq(ctx, pose, constraints, extra)
+	if err != nil {
+		return err
+	}
+	defer m.stopOnCancel(ctx)()
+
+	done, err := m.motion.Move(ctx, req)
+	if err != nil {
+		return err
+	}
+	if !done {
+		return fmt.Errorf("move didn't finish")
+	}
+	return nil
+}
+
+// plan plans moving the arm to pose in world with motion, without moving.
+func (m *armMover) plan(ctx context.Context, pose spatialmath.Pose, constraints *motionplan.Constraints, extra map[string]interface{}) (interface{}, error) {
+	if m.motion == nil {
+		return nil, fmt.Errorf("planning needs motion")
+	}
+
+	req, err := m.moveReq(ctx, pose, constraints, extra)
+	if err != nil {
+		return nil, err
+	}
+	return PlanMove(ctx, m.motion, req)
+}
+
+// moveReq is a request to move the arm to pose in world, with the obstacles, and the configured constraints plus constraints.
+func (m *armMover) moveReq(ctx context.Context, pose spatialmath.Pose, constraints *motionplan.Constraints, extra map[string]interface{}) (motion.MoveReq, error) {
+	ws, err := m.worldState(ctx)
+	if err != nil {
+		return motion.MoveReq{}, err
+	}
+
+	return motion.MoveReq{
+		ComponentName: m.armName,
+		Destination:   referenceframe.NewPoseInFrame("world", pose),
+		WorldState:    ws,
+		Constraints:   mergeConstraints(m.constraints, constraints),
+		Extra:         extra,
+	}, nil
+}
+
+// worldState has the geometries of the obstacles that aren't in the frame system, in world, nil if there are none.
+// Obstacles with a frame are left out, the frame system already has their geometries where their frame puts them,
+// so adding them again would put them in two places.
+func (m *armMover) worldState(ctx context.Context) (*referenceframe.WorldState, error) {
+	if len(m.obstacles) == 0 {
+		return nil, nil
+	}
+
+	var fsc *framesystem.Config
+	if m.fs != nil {
+		var err error
+		fsc, err = m.fs.FrameSystemConfig(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	gs := []spatialmath.Geometry{}
+	for _, o := range m.obstacles {
+		if fsc != nil && FindPart(fsc, o.name) != nil {
+			continue
+		}
+		g, err := o.shaped.Geometries(ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+		gs = append(gs, g...)
+	}
+
+	if len(gs) == 0 {
+		return nil, nil
+	}
+	return referenceframe.NewWorldState([]*referenceframe.GeometriesInFrame{referenceframe.NewGeometriesInFrame("world", gs)}, nil)
+}
+
+// mergeConstraints returns all of a's and b's constraints, either can be nil.
+func mergeConstraints(a, b *motionplan.Constraints) *motionplan.Constraints {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	return &motionplan.Constraints{
+		LinearConstraint:       slices.Concat(a.LinearConstraint, b.LinearConstraint),
+		PseudolinearConstraint: slices.Concat(a.PseudolinearConstraint, b.PseudolinearConstraint),
+		OrientationConstraint:  slices.Concat(a.OrientationConstraint, b.OrientationConstraint),
+		CollisionSpecification: slices.Concat(a.CollisionSpecification, b.CollisionSpecification),
+	}
 }
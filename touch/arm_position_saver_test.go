@@ -0,0 +1,562 @@
+package touch
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/geo/r3"
+
+	toggleswitch "go.viam.com/rdk/components/switch"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/motionplan"
+	"go.viam.com/rdk/referenceframe"
+	"go.viam.com/rdk/robot/framesystem"
+	"go.viam.com/rdk/spatialmath"
+	"go.viam.com/rdk/utils"
+	"go.viam.com/test"
+)
+
+func TestArmPositionSaverApproach(t *testing.T) {
+	ctx := context.Background()
+	forgetEntered("arm")
+	defer forgetEntered("arm")
+
+	cfg := &ArmPositionSaverConfig{
+		Arm:              "arm",
+		Motion:           "builtin",
+		Point:            r3.Vector{X: 100, Z: 50},
+		Orientation:      spatialmath.OrientationVectorDegrees{OZ: -1},
+		ApproachDistance: 30,
+	}
+	_, _, err := cfg.Validate("")
+	test.That(t, err, test.ShouldBeNil)
+
+	ms := &fakeMotionService{pose: spatialmath.NewPoseFromPoint(r3.Vector{Z: 500})}
+	aps := &ArmPositionSaver{
+		cfg:   cfg,
+		mover: &armMover{armName: "arm", motion: ms, logger: logging.NewTestLogger(t)},
+	}
+
+	n, names, err := aps.GetNumberOfPositions(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, n, test.ShouldEqual, 4)
+	test.That(t, names[3], test.ShouldEqual, "leave")
+
+	// pointing down, so the approach is above
+	err = aps.SetPosition(ctx, 2, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(ms.moves), test.ShouldEqual, 2)
+	test.That(t, spatialmath.R3VectorAlmostEqual(ms.moves[0].Destination.Pose().Point(), r3.Vector{X: 100, Z: 80}, 1e-6), test.ShouldBeTrue)
+	test.That(t, ms.moves[0].Constraints, test.ShouldBeNil)
+	test.That(t, spatialmath.R3VectorAlmostEqual(ms.moves[1].Destination.Pose().Point(), cfg.Point, 1e-6), test.ShouldBeTrue)
+	test.That(t, len(ms.moves[1].Constraints.LinearConstraint), test.ShouldEqual, 1)
+	test.That(t, ms.moves[1].Constraints.LinearConstraint[0].LineToleranceMm, test.ShouldEqual, 1)
+
+	// already there
+	err = aps.SetPosition(ctx, 2, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(ms.moves), test.ShouldEqual, 2)
+
+	err = aps.SetPosition(ctx, 3, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(ms.moves), test.ShouldEqual, 3)
+	test.That(t, spatialmath.R3VectorAlmostEqual(ms.moves[2].Destination.Pose().Point(), r3.Vector{X: 100, Z: 80}, 1e-6), test.ShouldBeTrue)
+	test.That(t, ms.moves[2].Constraints, test.ShouldNotBeNil)
+
+	// without an approach it goes straight there and there's no leave
+	cfg.ApproachDistance = 0
+	ms.pose = spatialmath.NewPoseFromPoint(r3.Vector{Z: 500})
+	err = aps.SetPosition(ctx, 2, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(ms.moves), test.ShouldEqual, 4)
+	test.That(t, ms.moves[3].Constraints, test.ShouldBeNil)
+	test.That(t, aps.SetPosition(ctx, 3, nil), test.ShouldNotBeNil)
+
+	cfg.ApproachDistance = 10
+	cfg.Motion = ""
+	_, _, err = cfg.Validate("")
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func TestArmPositionSaverWorldStateFrames(t *testing.T) {
+	ctx := context.Background()
+
+	// geometries are in the obstacle's own frame, bin's frame is 100 along x from world
+	inBin, err := spatialmath.NewBox(spatialmath.NewZeroPose(), r3.Vector{X: 10, Y: 10, Z: 10}, "in_bin")
+	test.That(t, err, test.ShouldBeNil)
+	loose, err := spatialmath.NewBox(spatialmath.NewZeroPose(), r3.Vector{X: 10, Y: 10, Z: 10}, "loose")
+	test.That(t, err, test.ShouldBeNil)
+
+	m := &armMover{
+		armName: "arm",
+		logger:  logging.NewTestLogger(t),
+		fs:      newFakeFrameSystemService(t),
+		obstacles: []armObstacle{
+			{name: "bin", shaped: &Obstacle{obstacles: []spatialmath.Geometry{inBin}}},
+			{name: "loose", shaped: &Obstacle{obstacles: []spatialmath.Geometry{loose}}},
+		},
+	}
+
+	// the frame system already has bin where its frame puts it, only the one without a frame is added
+	ws, err := m.worldState(ctx)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, ws.ObstacleNames(), test.ShouldContainKey, "loose")
+	test.That(t, ws.ObstacleNames(), test.ShouldNotContainKey, "in_bin")
+
+	m.obstacles = m.obstacles[:1]
+	ws, err = m.worldState(ctx)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, ws, test.ShouldBeNil)
+}
+
+// forgetEntered forgets the position the arm went into in an earlier test.
+func forgetEntered(armName string) {
+	enteredLock.Lock()
+	defer enteredLock.Unlock()
+	delete(entered, armName)
+}
+
+func TestArmPositionSaverBackOut(t *testing.T) {
+	ctx := context.Background()
+	forgetEntered("arm")
+	defer forgetEntered("arm")
+
+	down := spatialmath.OrientationVectorDegrees{OZ: -1}
+	ms := &fakeMotionService{pose: spatialmath.NewPoseFromPoint(r3.Vector{Z: 500})}
+	newSaver := func(x float64) *ArmPositionSaver {
+		return &ArmPositionSaver{
+			cfg:   &ArmPositionSaverConfig{Arm: "arm", Motion: "builtin", Point: r3.Vector{X: x}, Orientation: down, ApproachDistance: 30},
+			mover: &armMover{armName: "arm", motion: ms, logger: logging.NewTestLogger(t)},
+		}
+	}
+	a := newSaver(100)
+	b := newSaver(200)
+
+	test.That(t, a.SetPosition(ctx, 2, nil), test.ShouldBeNil)
+	test.That(t, len(ms.moves), test.ShouldEqual, 2)
+
+	// going to another position backs out of this one first
+	test.That(t, b.SetPosition(ctx, 2, nil), test.ShouldBeNil)
+	test.That(t, len(ms.moves), test.ShouldEqual, 5)
+	test.That(t, spatialmath.R3VectorAlmostEqual(ms.moves[2].Destination.Pose().Point(), r3.Vector{X: 100, Z: 30}, 1e-6), test.ShouldBeTrue)
+	test.That(t, len(ms.moves[2].Constraints.LinearConstraint), test.ShouldEqual, 1)
+	test.That(t, spatialmath.R3VectorAlmostEqual(ms.moves[3].Destination.Pose().Point(), r3.Vector{X: 200, Z: 30}, 1e-6), test.ShouldBeTrue)
+	test.That(t, ms.moves[3].Constraints, test.ShouldBeNil)
+
+	// once it's out, nothing to back out of
+	test.That(t, b.SetPosition(ctx, 3, nil), test.ShouldBeNil)
+	test.That(t, a.SetPosition(ctx, 2, nil), test.ShouldBeNil)
+	test.That(t, len(ms.moves), test.ShouldEqual, 8)
+	test.That(t, ms.moves[6].Constraints, test.ShouldBeNil)
+
+	// near the position, but not at it, it backs straight out before going back in
+	ms.pose = spatialmath.NewPose(r3.Vector{X: 100, Z: 5}, &down)
+	test.That(t, a.SetPosition(ctx, 2, nil), test.ShouldBeNil)
+	test.That(t, len(ms.moves), test.ShouldEqual, 10)
+	test.That(t, spatialmath.R3VectorAlmostEqual(ms.moves[8].Destination.Pose().Point(), r3.Vector{X: 100, Z: 30}, 1e-6), test.ShouldBeTrue)
+	test.That(t, len(ms.moves[8].Constraints.LinearConstraint), test.ShouldEqual, 1)
+	test.That(t, len(ms.moves[9].Constraints.LinearConstraint), test.ShouldEqual, 1)
+
+	// if the arm was moved away some other way, there's nothing to back out of
+	ms.pose = spatialmath.NewPoseFromPoint(r3.Vector{Z: 500})
+	test.That(t, b.SetPosition(ctx, 2, nil), test.ShouldBeNil)
+	test.That(t, len(ms.moves), test.ShouldEqual, 12)
+	test.That(t, ms.moves[10].Constraints, test.ShouldBeNil)
+}
+
+func TestArmPositionSaverLeave(t *testing.T) {
+	ctx := context.Background()
+	forgetEntered("arm")
+	defer forgetEntered("arm")
+
+	down := spatialmath.OrientationVectorDegrees{OZ: -1}
+	ms := &fakeMotionService{pose: spatialmath.NewPose(r3.Vector{X: 300, Z: 5}, &down)}
+	newSaver := func() *ArmPositionSaver {
+		return &ArmPositionSaver{
+			cfg:   &ArmPositionSaverConfig{Arm: "arm", Motion: "builtin", Point: r3.Vector{X: 100}, Orientation: down, ApproachDistance: 30},
+			mover: &armMover{armName: "arm", motion: ms, logger: logging.NewTestLogger(t)},
+		}
+	}
+
+	// nothing gone to yet, so nothing to back out of, even if the arm is inside some other position
+	aps := newSaver()
+	test.That(t, aps.SetPosition(ctx, 3, nil), test.ShouldBeNil)
+	test.That(t, len(ms.moves), test.ShouldEqual, 0)
+
+	// moved away some other way since going in
+	test.That(t, aps.SetPosition(ctx, 2, nil), test.ShouldBeNil)
+	test.That(t, len(ms.moves), test.ShouldEqual, 2)
+	ms.pose = spatialmath.NewPoseFromPoint(r3.Vector{Z: 500})
+	test.That(t, aps.SetPosition(ctx, 3, nil), test.ShouldBeNil)
+	test.That(t, len(ms.moves), test.ShouldEqual, 2)
+	pos, err := aps.GetPosition(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, pos, test.ShouldEqual, 0)
+
+	// a rebuilt saver still backs out of where the arm went in
+	test.That(t, aps.SetPosition(ctx, 2, nil), test.ShouldBeNil)
+	test.That(t, len(ms.moves), test.ShouldEqual, 4)
+	aps = newSaver()
+	test.That(t, aps.SetPosition(ctx, 3, nil), test.ShouldBeNil)
+	test.That(t, len(ms.moves), test.ShouldEqual, 5)
+	test.That(t, spatialmath.R3VectorAlmostEqual(ms.moves[4].Destination.Pose().Point(), r3.Vector{X: 100, Z: 30}, 1e-6), test.ShouldBeTrue)
+	test.That(t, ms.moves[4].Constraints, test.ShouldNotBeNil)
+}
+
+func TestArmPositionSaverBackOutRebuilt(t *testing.T) {
+	ctx := context.Background()
+	forgetEntered("arm")
+	defer forgetEntered("arm")
+
+	down := spatialmath.OrientationVectorDegrees{OZ: -1}
+	newSaver := func(name string, x float64, ms *fakeMotionService) *ArmPositionSaver {
+		n := toggleswitch.Named(name)
+		return &ArmPositionSaver{
+			name:  n,
+			cfg:   &ArmPositionSaverConfig{Arm: "arm", Motion: "builtin", Point: r3.Vector{X: x}, Orientation: down, ApproachDistance: 30},
+			mover: &armMover{saver: n, armName: "arm", motion: ms, logger: logging.NewTestLogger(t)},
+		}
+	}
+
+	old := &fakeMotionService{pose: spatialmath.NewPoseFromPoint(r3.Vector{Z: 500})}
+	a := newSaver("a", 100, old)
+	test.That(t, a.SetPosition(ctx, 2, nil), test.ShouldBeNil)
+	test.That(t, len(old.moves), test.ShouldEqual, 2)
+
+	// another saver backs out with its own motion, not the one that went in
+	current := &fakeMotionService{pose: old.pose}
+	b := newSaver("b", 200, current)
+	test.That(t, b.SetPosition(ctx, 2, nil), test.ShouldBeNil)
+	test.That(t, len(old.moves), test.ShouldEqual, 2)
+	test.That(t, len(current.moves), test.ShouldEqual, 3)
+	test.That(t, spatialmath.R3VectorAlmostEqual(current.moves[0].Destination.Pose().Point(), r3.Vector{X: 100, Z: 30}, 1e-6), test.ShouldBeTrue)
+
+	// closing a saver forgets where it went in, but not where another saver went in
+	test.That(t, a.Close(ctx), test.ShouldBeNil)
+	enteredLock.Lock()
+	test.That(t, entered, test.ShouldContainKey, "arm")
+	enteredLock.Unlock()
+
+	test.That(t, b.Close(ctx), test.ShouldBeNil)
+	enteredLock.Lock()
+	test.That(t, entered, test.ShouldNotContainKey, "arm")
+	enteredLock.Unlock()
+}
+
+func TestArmPositionSaverStrategy(t *testing.T) {
+	ctx := context.Background()
+
+	cfg := &ArmPositionSaverConfig{
+		Arm:         "arm",
+		Motion:      "builtin",
+		Joints:      []float64{1, 2},
+		Point:       r3.Vector{X: 100},
+		Orientation: spatialmath.OrientationVectorDegrees{OZ: -1},
+	}
+	_, _, err := cfg.Validate("")
+	test.That(t, err, test.ShouldBeNil)
+
+	a := &fakeArm{joints: []referenceframe.Input{0, 0}}
+	ms := &fakeMotionService{pose: spatialmath.NewPoseFromPoint(r3.Vector{Z: 500})}
+	aps := &ArmPositionSaver{
+		cfg:   cfg,
+		mover: &armMover{armName: "arm", arm: a, motion: ms, logger: logging.NewTestLogger(t)},
+	}
+
+	// with motion and a pose, the default is to plan
+	res, err := aps.DoCommand(ctx, map[string]interface{}{"go_to": true})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, res["strategy"], test.ShouldEqual, "motion-plan")
+	test.That(t, len(ms.moves), test.ShouldEqual, 1)
+	test.That(t, ms.moves[0].Extra, test.ShouldBeNil)
+	test.That(t, a.joints, test.ShouldResemble, []referenceframe.Input{0, 0})
+
+	err = aps.SetPosition(ctx, 2, map[string]interface{}{"strategy": "joints"})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, a.joints, test.ShouldResemble, []referenceframe.Input{1, 2})
+	test.That(t, len(ms.moves), test.ShouldEqual, 1)
+
+	// the fake arm doesn't move the pose, so it's verified where motion left it
+	res, err = aps.DoCommand(ctx, map[string]interface{}{"go_to": map[string]interface{}{"strategy": "joints-then-verify-pose"}})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, res["linear_error_mm"], test.ShouldAlmostEqual, 0)
+	test.That(t, res["orientation_error_degs"], test.ShouldAlmostEqual, 0)
+
+	ms.pose = spatialmath.NewPose(r3.Vector{X: 103}, &spatialmath.OrientationVectorDegrees{OZ: -1})
+	err = aps.SetPosition(ctx, 2, map[string]interface{}{"strategy": "joints-then-verify-pose"})
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "3.00 mm")
+
+	cfg.VerifyLinearToleranceMm = 5
+	err = aps.SetPosition(ctx, 2, map[string]interface{}{"strategy": "joints-then-verify-pose"})
+	test.That(t, err, test.ShouldBeNil)
+
+	test.That(t, aps.SetPosition(ctx, 2, map[string]interface{}{"strategy": "teleport"}), test.ShouldNotBeNil)
+
+	// without motion only joints work
+	cfg.Motion = ""
+	aps.mover.motion = nil
+	res, err = aps.DoCommand(ctx, map[string]interface{}{"go_to": true})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, res["strategy"], test.ShouldEqual, "joints")
+	test.That(t, aps.SetPosition(ctx, 2, map[string]interface{}{"strategy": "motion-plan"}), test.ShouldNotBeNil)
+
+	cfg.Extra = map[string]interface{}{"strategy": "motion-plan"}
+	_, _, err = cfg.Validate("")
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func TestArmPositionSaverWorldState(t *testing.T) {
+	ctx := context.Background()
+	forgetEntered("arm")
+	defer forgetEntered("arm")
+
+	cfg := &ArmPositionSaverConfig{
+		Arm:              "arm",
+		Motion:           "builtin",
+		Point:            r3.Vector{X: 100},
+		Orientation:      spatialmath.OrientationVectorDegrees{OZ: -1},
+		ApproachDistance: 30,
+		Obstacles:        []string{"box"},
+		Constraints: &motionplan.Constraints{
+			CollisionSpecification: []motionplan.CollisionSpecification{
+				{Allows: []motionplan.CollisionSpecificationAllowedFrameCollisions{{Frame1: "gripper", Frame2: "box"}}},
+			},
+		},
+	}
+	deps, _, err := cfg.Validate("")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, deps, test.ShouldContain, "box")
+	test.That(t, deps, test.ShouldContain, framesystem.PublicServiceName.String())
+
+	box, err := spatialmath.NewBox(spatialmath.NewZeroPose(), r3.Vector{X: 10, Y: 10, Z: 10}, "box")
+	test.That(t, err, test.ShouldBeNil)
+
+	planned := ""
+	ms := &fakeMotionService{
+		pose: spatialmath.NewPoseFromPoint(r3.Vector{Z: 500}),
+		plan: func(req string) (interface{}, error) {
+			planned = req
+			return []interface{}{"a"}, nil
+		},
+	}
+	aps := &ArmPositionSaver{
+		cfg: cfg,
+		mover: &armMover{
+			armName:     "arm",
+			motion:      ms,
+			logger:      logging.NewTestLogger(t),
+			obstacles:   []armObstacle{{name: "box", shaped: &Obstacle{obstacles: []spatialmath.Geometry{box}}}},
+			constraints: cfg.Constraints,
+		},
+	}
+
+	// plans to the approach point without moving
+	res, err := aps.DoCommand(ctx, map[string]interface{}{"plan": true})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, res["plan"], test.ShouldResemble, []interface{}{"a"})
+	test.That(t, len(ms.moves), test.ShouldEqual, 0)
+	test.That(t, planned, test.ShouldContainSubstring, "box")
+
+	err = aps.SetPosition(ctx, 2, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(ms.moves), test.ShouldEqual, 2)
+	for _, m := range ms.moves {
+		test.That(t, m.WorldState, test.ShouldNotBeNil)
+		test.That(t, m.WorldState.ObstacleNames(), test.ShouldContainKey, "box")
+		test.That(t, len(m.Constraints.CollisionSpecification), test.ShouldEqual, 1)
+	}
+	test.That(t, len(ms.moves[0].Constraints.LinearConstraint), test.ShouldEqual, 0)
+	test.That(t, len(ms.moves[1].Constraints.LinearConstraint), test.ShouldEqual, 1)
+
+	cfg.Motion = ""
+	_, _, err = cfg.Validate("")
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func TestArmPositionSaverHistory(t *testing.T) {
+	ctx := context.Background()
+	s := &fileAttributeStore{path: filepath.Join(t.TempDir(), "saver.json")}
+	attrs := utils.AttributeMap{"arm": "arm", "history_size": 2}
+
+	cfg, err := loadSavedConfig[*ArmPositionSaverConfig](ctx, s, attrs)
+	test.That(t, err, test.ShouldBeNil)
+
+	a := &fakeArm{joints: []referenceframe.Input{1}}
+	aps := &ArmPositionSaver{
+		cfg:   cfg,
+		store: s,
+		mover: &armMover{armName: "arm", arm: a, logger: logging.NewTestLogger(t)},
+	}
+
+	// nothing to replace the first time
+	test.That(t, aps.SetPosition(ctx, 1, nil), test.ShouldBeNil)
+	test.That(t, aps.config().History, test.ShouldBeEmpty)
+
+	for _, j := range []float64{2, 3, 4} {
+		a.joints = []referenceframe.Input{j}
+		test.That(t, aps.SetPosition(ctx, 1, nil), test.ShouldBeNil)
+	}
+
+	// newest first, and only history_size of them
+	res, err := aps.DoCommand(ctx, map[string]interface{}{"history": true})
+	test.That(t, err, test.ShouldBeNil)
+	history := res["history"].([]interface{})
+	test.That(t, len(history), test.ShouldEqual, 2)
+	test.That(t, history[0].(map[string]interface{})["position"], test.ShouldResemble, map[string]interface{}{"joints": []interface{}{3.0}})
+	test.That(t, history[0].(map[string]interface{})["saved"], test.ShouldNotBeEmpty)
+	test.That(t, aps.config().History[1].Position.Joints, test.ShouldResemble, []float64{2})
+
+	// from a go caller, the index doesn't have to be a float64
+	saved := aps.config().History[1].Saved
+	_, err = aps.DoCommand(ctx, map[string]interface{}{"restore": 1})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, aps.config().Joints, test.ShouldResemble, []float64{2})
+	test.That(t, aps.config().Saved, test.ShouldEqual, saved)
+	test.That(t, aps.config().History[0].Position.Joints, test.ShouldResemble, []float64{4})
+	test.That(t, aps.config().History[1].Position.Joints, test.ShouldResemble, []float64{3})
+
+	_, err = aps.DoCommand(ctx, map[string]interface{}{"restore": int64(2)})
+	test.That(t, err, test.ShouldNotBeNil)
+
+	_, err = aps.DoCommand(ctx, map[string]interface{}{"restore": 0.7})
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, aps.config().Joints, test.ShouldResemble, []float64{2})
+
+	// undoing the restore
+	_, err = aps.DoCommand(ctx, map[string]interface{}{"restore": 0.0})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, aps.config().Joints, test.ShouldResemble, []float64{4})
+
+	// it's all saved
+	cfg, err = loadSavedConfig[*ArmPositionSaverConfig](ctx, s, attrs)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, cfg.Joints, test.ShouldResemble, []float64{4})
+	test.That(t, cfg.History, test.ShouldResemble, aps.config().History)
+	test.That(t, cfg.position().hasPose(), test.ShouldBeFalse)
+}
+
+func TestArmPositionSaverPoses(t *testing.T) {
+	ctx := context.Background()
+	s := &fileAttributeStore{path: filepath.Join(t.TempDir(), "saver.json")}
+	attrs := utils.AttributeMap{"arm": "arm"}
+
+	cfg, err := loadSavedConfig[*ArmPositionSaverConfig](ctx, s, attrs)
+	test.That(t, err, test.ShouldBeNil)
+
+	a := &fakeArm{joints: []referenceframe.Input{1, 2}}
+	aps := &ArmPositionSaver{
+		cfg:   cfg,
+		store: s,
+		mover: &armMover{armName: "arm", arm: a, logger: logging.NewTestLogger(t)},
+	}
+
+	n, names, err := aps.GetNumberOfPositions(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, n, test.ShouldEqual, 4)
+	test.That(t, names, test.ShouldResemble, []string{"idle", "update config", "go to", "leave"})
+
+	// leave is there without an approach, but doesn't do anything
+	test.That(t, aps.SetPosition(ctx, 3, nil), test.ShouldNotBeNil)
+
+	_, err = aps.DoCommand(ctx, map[string]interface{}{"save": "a"})
+	test.That(t, err, test.ShouldBeNil)
+	a.joints = []referenceframe.Input{3, 4}
+	_, err = aps.DoCommand(ctx, map[string]interface{}{"save": "b"})
+	test.That(t, err, test.ShouldBeNil)
+
+	// each pose is a position after the fixed ones
+	n, names, err = aps.GetNumberOfPositions(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, n, test.ShouldEqual, 6)
+	test.That(t, names[4:], test.ShouldResemble, []string{"a", "b"})
+
+	// turning the approach on or off doesn't move them
+	withApproach := *aps.config()
+	withApproach.ApproachDistance = 30
+	test.That(t, withApproach.positionNames(), test.ShouldResemble, names)
+
+	// 0 is reserved for not having gone anywhere
+	p, err := aps.GetPosition(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, p, test.ShouldEqual, 0)
+
+	err = aps.SetPosition(ctx, 4, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, a.joints, test.ShouldResemble, []referenceframe.Input{1, 2})
+	p, err = aps.GetPosition(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, p, test.ShouldEqual, 4)
+
+	res, err := aps.DoCommand(ctx, map[string]interface{}{"go_to": "b"})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, res["strategy"], test.ShouldEqual, "joints")
+	test.That(t, a.joints, test.ShouldResemble, []referenceframe.Input{3, 4})
+	p, err = aps.GetPosition(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, p, test.ShouldEqual, 5)
+
+	test.That(t, aps.SetPosition(ctx, 6, nil), test.ShouldNotBeNil)
+	_, err = aps.DoCommand(ctx, map[string]interface{}{"go_to": "x"})
+	test.That(t, err, test.ShouldNotBeNil)
+
+	// saving over a pose replaces it in place, and the old one goes in the history
+	a.joints = []referenceframe.Input{5, 6}
+	_, err = aps.DoCommand(ctx, map[string]interface{}{"save": "a"})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, aps.config().Poses[0].Joints, test.ShouldResemble, []float64{5, 6})
+	test.That(t, len(aps.config().Poses), test.ShouldEqual, 2)
+	test.That(t, aps.config().History[0].Position.Name, test.ShouldEqual, "a")
+	test.That(t, aps.config().History[0].Position.Joints, test.ShouldResemble, []float64{1, 2})
+	p, err = aps.GetPosition(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, p, test.ShouldEqual, 0)
+
+	_, err = aps.DoCommand(ctx, map[string]interface{}{"rename": map[string]interface{}{"from": "a", "to": "c"}})
+	test.That(t, err, test.ShouldBeNil)
+	_, err = aps.DoCommand(ctx, map[string]interface{}{"rename": map[string]interface{}{"from": "c", "to": "b"}})
+	test.That(t, err, test.ShouldNotBeNil)
+	_, err = aps.DoCommand(ctx, map[string]interface{}{"rename": map[string]interface{}{"from": "x", "to": "y"}})
+	test.That(t, err, test.ShouldNotBeNil)
+
+	_, err = aps.DoCommand(ctx, map[string]interface{}{"delete": "b"})
+	test.That(t, err, test.ShouldBeNil)
+	_, err = aps.DoCommand(ctx, map[string]interface{}{"delete": "b"})
+	test.That(t, err, test.ShouldNotBeNil)
+
+	res, err = aps.DoCommand(ctx, map[string]interface{}{"list": true})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, res["names"], test.ShouldResemble, []string{"c"})
+
+	// a deleted pose can be restored
+	test.That(t, aps.config().History[0].Position.Name, test.ShouldEqual, "b")
+	_, err = aps.DoCommand(ctx, map[string]interface{}{"restore": 0.0})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, aps.config().poseNames(), test.ShouldResemble, []string{"c", "b"})
+	test.That(t, aps.config().Poses[1].Joints, test.ShouldResemble, []float64{3, 4})
+
+	// named poses don't touch the unnamed position
+	test.That(t, aps.config().Joints, test.ShouldBeEmpty)
+
+	// it's all saved
+	cfg, err = loadSavedConfig[*ArmPositionSaverConfig](ctx, s, attrs)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, cfg.Poses, test.ShouldResemble, aps.config().Poses)
+	test.That(t, cfg.History, test.ShouldResemble, aps.config().History)
+}
+
+func TestArmPositionSaverPosesConfig(t *testing.T) {
+	cfg := &ArmPositionSaverConfig{Arm: "arm", Motion: "builtin", Poses: []SavedPosition{{Name: "a"}, {Name: "b"}}}
+	deps, _, err := cfg.Validate("")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(deps), test.ShouldEqual, 2)
+
+	cfg.Poses = append(cfg.Poses, SavedPosition{Name: "a"})
+	_, _, err = cfg.Validate("")
+	test.That(t, err, test.ShouldNotBeNil)
+
+	cfg.Poses = []SavedPosition{{}}
+	_, _, err = cfg.Validate("")
+	test.That(t, err, test.ShouldNotBeNil)
+}
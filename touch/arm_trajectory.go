@@ -0,0 +1,343 @@
+package touch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	toggleswitch "go.viam.com/rdk/components/switch"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+
+	"github.com/erh/vmodutils"
+)
+
+var ArmTrajectoryModel = vmodutils.NamespaceFamily.WithModel("arm-trajectory")
+
+func init() {
+	resource.RegisterComponent(
+		toggleswitch.API,
+		ArmTrajectoryModel,
+		resource.Registration[toggleswitch.Switch, *ArmTrajectoryConfig]{
+			Constructor: newArmTrajectory,
+		})
+}
+
+// TrajectorySegment is a move to one arm-position-saver.
+type TrajectorySegment struct {
+	Position string `json:"position"` // arm-position-saver
+
+	// override the trajectory's defaults for this segment, 0 means use the default
+	MaxVelDegsPerSec  float64 `json:"max_vel_degs_per_sec"`
+	MaxAccDegsPerSec2 float64 `json:"max_acc_degs_per_sec2"`
+
+	DwellSeconds float64 `json:"dwell_seconds"` // how long to wait after getting there
+}
+
+type ArmTrajectoryConfig struct {
+	Segments []TrajectorySegment `json:"segments"`
+
+	// defaults for every segment, 0 means the arm's default
+	MaxVelDegsPerSec  float64 `json:"max_vel_degs_per_sec"`
+	MaxAccDegsPerSec2 float64 `json:"max_acc_degs_per_sec2"`
+
+	Loops           int  `json:"loops"`             // how many times to play the segments, defaults to 1
+	ContinueOnError bool `json:"continue_on_error"` // if a segment fails keep going, otherwise stop
+}
+
+func (c *ArmTrajectoryConfig) loops() int {
+	if c.Loops <= 0 {
+		return 1
+	}
+	return c.Loops
+}
+
+// extra is what's passed to the arm-position-saver for s.
+// Speed limits only apply to joint moves, so with them the saver is told to move the joints.
+func (c *ArmTrajectoryConfig) extra(s TrajectorySegment) map[string]interface{} {
+	extra := map[string]interface{}{}
+
+	vel := c.MaxVelDegsPerSec
+	if s.MaxVelDegsPerSec > 0 {
+		vel = s.MaxVelDegsPerSec
+	}
+	if vel > 0 {
+		extra["max_vel_degs_per_sec"] = vel
+	}
+
+	acc := c.MaxAccDegsPerSec2
+	if s.MaxAccDegsPerSec2 > 0 {
+		acc = s.MaxAccDegsPerSec2
+	}
+	if acc > 0 {
+		extra["max_acc_degs_per_sec2"] = acc
+	}
+
+	if len(extra) > 0 {
+		extra["strategy"] = strategyJoints
+	}
+
+	return extra
+}
+
+func (c *ArmTrajectoryConfig) Validate(path string) ([]string, []string, error) {
+	if len(c.Segments) == 0 {
+		return nil, nil, fmt.Errorf("no segments")
+	}
+	if c.MaxVelDegsPerSec < 0 || c.MaxAccDegsPerSec2 < 0 {
+		return nil, nil, fmt.Errorf("max_vel_degs_per_sec and max_acc_degs_per_sec2 can't be negative")
+	}
+
+	deps := []string{}
+	for i, s := range c.Segments {
+		if s.Position == "" {
+			return nil, nil, fmt.Errorf("segment %d needs a position", i)
+		}
+		if s.MaxVelDegsPerSec < 0 || s.MaxAccDegsPerSec2 < 0 || s.DwellSeconds < 0 {
+			return nil, nil, fmt.Errorf("segment %d can't have negative speeds or dwell", i)
+		}
+		deps = append(deps, s.Position)
+	}
+
+	return deps, nil, nil
+}
+
+func newArmTrajectory(ctx context.Context, deps resource.Dependencies, config resource.Config, logger logging.Logger) (toggleswitch.Switch, error) {
+	newConf, err := resource.NativeConfig[*ArmTrajectoryConfig](config)
+	if err != nil {
+		return nil, err
+	}
+
+	at := &ArmTrajectory{
+		name:   config.ResourceName(),
+		cfg:    newConf,
+		logger: logger,
+	}
+
+	for _, s := range newConf.Segments {
+		p, err := toggleswitch.FromProvider(deps, s.Position)
+		if err != nil {
+			return nil, err
+		}
+		at.positions = append(at.positions, p)
+	}
+
+	return at, nil
+}
+
+// ArmTrajectory plays a list of arm-position-savers in order.
+type ArmTrajectory struct {
+	resource.AlwaysRebuild
+
+	name   resource.Name
+	cfg    *ArmTrajectoryConfig
+	logger logging.Logger
+
+	positions []toggleswitch.Switch // one per segment
+
+	lock     sync.Mutex
+	cancel   context.CancelFunc // nil if not playing
+	done     chan struct{}      // closed when the current playback finishes
+	progress TrajectoryProgress
+}
+
+// TrajectoryProgress is where a playback is.
+type TrajectoryProgress struct {
+	Playing  bool
+	Loop     int // 0 based
+	Segment  int // 0 based
+	Started  time.Time
+	Finished time.Time
+	Errors   []error // segments that failed
+	Err      error   // why it stopped, nil if it finished
+}
+
+func (p TrajectoryProgress) toMap(cfg *ArmTrajectoryConfig) map[string]interface{} {
+	errs := []string{}
+	for _, err := range p.Errors {
+		errs = append(errs, err.Error())
+	}
+
+	m := map[string]interface{}{
+		"playing":  p.Playing,
+		"loop":     p.Loop,
+		"loops":    cfg.loops(),
+		"segment":  p.Segment,
+		"segments": len(cfg.Segments),
+		"position": cfg.Segments[p.Segment].Position,
+		"errors":   errs,
+	}
+	if !p.Started.IsZero() {
+		m["started"] = p.Started.Format(time.RFC3339Nano)
+	}
+	if !p.Finished.IsZero() {
+		m["finished"] = p.Finished.Format(time.RFC3339Nano)
+	}
+	if p.Err != nil {
+		m["error"] = p.Err.Error()
+	}
+	return m
+}
+
+func (at *ArmTrajectory) Name() resource.Name {
+	return at.name
+}
+
+// DoCommand
+//
+//	{"start" : true} - starts playing in the background, fails if already playing
+//	{"progress" : true} - where the current or last playback is
+//	{"cancel" : true} - stops playing, and waits for it to stop, the arm-position-saver stops the arm mid move
+func (at *ArmTrajectory) DoCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	if cmd["start"] == true {
+		_, err := at.start()
+		return nil, err
+	}
+
+	if cmd["progress"] == true {
+		at.lock.Lock()
+		defer at.lock.Unlock()
+		return at.progress.toMap(at.cfg), nil
+	}
+
+	if cmd["cancel"] == true {
+		return nil, at.stop(ctx)
+	}
+
+	return nil, fmt.Errorf("unknown command %v", cmd)
+}
+
+// start starts playing in the background, done is closed when it's finished.
+func (at *ArmTrajectory) start() (chan struct{}, error) {
+	at.lock.Lock()
+	defer at.lock.Unlock()
+
+	if at.cancel != nil {
+		return nil, fmt.Errorf("already playing")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	at.cancel = cancel
+	at.done = done
+	at.progress = TrajectoryProgress{Playing: true, Started: time.Now()}
+
+	go func() {
+		defer close(done)
+		err := at.play(ctx)
+
+		at.lock.Lock()
+		defer at.lock.Unlock()
+		at.cancel()
+		at.cancel = nil
+		at.progress.Playing = false
+		at.progress.Finished = time.Now()
+		at.progress.Err = err
+	}()
+
+	return done, nil
+}
+
+// stop cancels the current playback, if there is one, and waits for it to stop.
+func (at *ArmTrajectory) stop(ctx context.Context) error {
+	at.lock.Lock()
+	cancel, done := at.cancel, at.done
+	at.lock.Unlock()
+
+	if cancel == nil {
+		return nil
+	}
+	cancel()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (at *ArmTrajectory) play(ctx context.Context) error {
+	for loop := 0; loop < at.cfg.loops(); loop++ {
+		for i, s := range at.cfg.Segments {
+			at.lock.Lock()
+			at.progress.Loop = loop
+			at.progress.Segment = i
+			at.lock.Unlock()
+
+			err := at.positions[i].SetPosition(ctx, 2, at.cfg.extra(s))
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if err != nil {
+				err = fmt.Errorf("loop %d segment %d [%s] failed: %w", loop, i, s.Position, err)
+				if !at.cfg.ContinueOnError {
+					return err
+				}
+				at.logger.Warn(err)
+				at.lock.Lock()
+				at.progress.Errors = append(at.progress.Errors, err)
+				at.lock.Unlock()
+				continue
+			}
+
+			if s.DwellSeconds > 0 {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(time.Duration(s.DwellSeconds * float64(time.Second))):
+				}
+			}
+		}
+	}
+
+	at.lock.Lock()
+	defer at.lock.Unlock()
+	return errors.Join(at.progress.Errors...)
+}
+
+// SetPosition 1 plays the trajectory and waits for it to finish, 0 stops it.
+func (at *ArmTrajectory) SetPosition(ctx context.Context, position uint32, extra map[string]interface{}) error {
+	if position == 0 {
+		return at.stop(ctx)
+	}
+
+	if position == 1 {
+		done, err := at.start()
+		if err != nil {
+			return err
+		}
+
+		select {
+		case <-done:
+		case <-ctx.Done():
+			// the caller went away, so stop moving
+			return errors.Join(ctx.Err(), at.stop(context.Background()))
+		}
+
+		at.lock.Lock()
+		defer at.lock.Unlock()
+		return at.progress.Err
+	}
+
+	return fmt.Errorf("bad position: %d", position)
+}
+
+func (at *ArmTrajectory) GetPosition(ctx context.Context, extra map[string]interface{}) (uint32, error) {
+	at.lock.Lock()
+	defer at.lock.Unlock()
+	if at.progress.Playing {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+func (at *ArmTrajectory) GetNumberOfPositions(ctx context.Context, extra map[string]interface{}) (uint32, []string, error) {
+	return 2, []string{"stopped", "play"}, nil
+}
+
+func (at *ArmTrajectory) Close(ctx context.Context) error {
+	return at.stop(ctx)
+}
@@ -0,0 +1,281 @@
+package touch
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/geo/r3"
+
+	toggleswitch "go.viam.com/rdk/components/switch"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/referenceframe"
+	"go.viam.com/rdk/spatialmath"
+	"go.viam.com/test"
+)
+
+// fakeTrajectoryPosition records what it was asked to do.
+type fakeTrajectoryPosition struct {
+	toggleswitch.Switch
+	name string
+	log  *[]string
+	lock *sync.Mutex
+	fn   func(ctx context.Context, extra map[string]interface{}) error // optional
+}
+
+func (f *fakeTrajectoryPosition) SetPosition(ctx context.Context, position uint32, extra map[string]interface{}) error {
+	f.lock.Lock()
+	*f.log = append(*f.log, f.name)
+	f.lock.Unlock()
+	if f.fn != nil {
+		return f.fn(ctx, extra)
+	}
+	return nil
+}
+
+func makeTestArmTrajectory(t *testing.T, cfg *ArmTrajectoryConfig, fns map[string]func(context.Context, map[string]interface{}) error) (*ArmTrajectory, func() []string) {
+	log := []string{}
+	lock := &sync.Mutex{}
+
+	at := &ArmTrajectory{cfg: cfg, logger: logging.NewTestLogger(t)}
+	for _, s := range cfg.Segments {
+		at.positions = append(at.positions, &fakeTrajectoryPosition{name: s.Position, log: &log, lock: lock, fn: fns[s.Position]})
+	}
+
+	return at, func() []string {
+		lock.Lock()
+		defer lock.Unlock()
+		return append([]string{}, log...)
+	}
+}
+
+func TestArmTrajectory(t *testing.T) {
+	ctx := context.Background()
+
+	extras := map[string]map[string]interface{}{}
+	record := func(name string) func(context.Context, map[string]interface{}) error {
+		return func(ctx context.Context, extra map[string]interface{}) error {
+			extras[name] = extra
+			return nil
+		}
+	}
+
+	cfg := &ArmTrajectoryConfig{
+		Segments: []TrajectorySegment{
+			{Position: "a", MaxVelDegsPerSec: 10},
+			{Position: "b", DwellSeconds: .01},
+		},
+		MaxVelDegsPerSec:  5,
+		MaxAccDegsPerSec2: 20,
+		Loops:             2,
+	}
+	deps, _, err := cfg.Validate("")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, deps, test.ShouldResemble, []string{"a", "b"})
+
+	at, log := makeTestArmTrajectory(t, cfg, map[string]func(context.Context, map[string]interface{}) error{
+		"a": record("a"),
+		"b": record("b"),
+	})
+
+	err = at.SetPosition(ctx, 1, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, log(), test.ShouldResemble, []string{"a", "b", "a", "b"})
+	test.That(t, extras["a"], test.ShouldResemble, map[string]interface{}{"max_vel_degs_per_sec": 10.0, "max_acc_degs_per_sec2": 20.0, "strategy": "joints"})
+	test.That(t, extras["b"], test.ShouldResemble, map[string]interface{}{"max_vel_degs_per_sec": 5.0, "max_acc_degs_per_sec2": 20.0, "strategy": "joints"})
+
+	res, err := at.DoCommand(ctx, map[string]interface{}{"progress": true})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, res["playing"], test.ShouldBeFalse)
+	test.That(t, res["loop"], test.ShouldEqual, 1)
+	test.That(t, res["position"], test.ShouldEqual, "b")
+	test.That(t, res["finished"], test.ShouldNotBeNil)
+
+	cfg.Segments[0].Position = ""
+	_, _, err = cfg.Validate("")
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func TestArmTrajectoryErrors(t *testing.T) {
+	ctx := context.Background()
+	broken := map[string]func(context.Context, map[string]interface{}) error{
+		"b": func(ctx context.Context, extra map[string]interface{}) error { return errors.New("can't get there") },
+	}
+
+	cfg := &ArmTrajectoryConfig{Segments: []TrajectorySegment{{Position: "a"}, {Position: "b"}, {Position: "c"}}}
+	at, log := makeTestArmTrajectory(t, cfg, broken)
+	err := at.SetPosition(ctx, 1, nil)
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, log(), test.ShouldResemble, []string{"a", "b"})
+
+	cfg.ContinueOnError = true
+	at, log = makeTestArmTrajectory(t, cfg, broken)
+	err = at.SetPosition(ctx, 1, nil)
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, log(), test.ShouldResemble, []string{"a", "b", "c"})
+
+	res, err := at.DoCommand(ctx, map[string]interface{}{"progress": true})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(res["errors"].([]string)), test.ShouldEqual, 1)
+}
+
+func TestArmTrajectoryCancel(t *testing.T) {
+	ctx := context.Background()
+
+	started := make(chan struct{}, 1)
+	cfg := &ArmTrajectoryConfig{Segments: []TrajectorySegment{{Position: "a"}, {Position: "b"}}}
+	at, log := makeTestArmTrajectory(t, cfg, map[string]func(context.Context, map[string]interface{}) error{
+		"a": func(ctx context.Context, extra map[string]interface{}) error {
+			started <- struct{}{}
+			<-ctx.Done()
+			return ctx.Err()
+		},
+	})
+
+	_, err := at.DoCommand(ctx, map[string]interface{}{"start": true})
+	test.That(t, err, test.ShouldBeNil)
+	<-started
+
+	_, err = at.DoCommand(ctx, map[string]interface{}{"start": true})
+	test.That(t, err, test.ShouldNotBeNil)
+	pos, err := at.GetPosition(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, pos, test.ShouldEqual, 1)
+
+	_, err = at.DoCommand(ctx, map[string]interface{}{"cancel": true})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, log(), test.ShouldResemble, []string{"a"})
+
+	res, err := at.DoCommand(ctx, map[string]interface{}{"progress": true})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, res["playing"], test.ShouldBeFalse)
+	test.That(t, res["error"], test.ShouldEqual, context.Canceled.Error())
+
+	// a caller going away stops it too
+	cctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	err = at.SetPosition(cctx, 1, nil)
+	test.That(t, errors.Is(err, context.DeadlineExceeded), test.ShouldBeTrue)
+	pos, err = at.GetPosition(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, pos, test.ShouldEqual, 0)
+}
+
+// blockingArm's moves only finish once it's stopped, like an arm that ignores the context of a move.
+type blockingArm struct {
+	fakeArm
+	moving  chan struct{}
+	stopped chan struct{}
+}
+
+func (b *blockingArm) MoveToJointPositions(ctx context.Context, positions []referenceframe.Input, extra map[string]interface{}) error {
+	close(b.moving)
+	<-b.stopped
+	return errors.New("stopped")
+}
+
+func (b *blockingArm) Stop(ctx context.Context, extra map[string]interface{}) error {
+	close(b.stopped)
+	return nil
+}
+
+func TestArmTrajectoryCancelStopsArm(t *testing.T) {
+	ctx := context.Background()
+
+	a := &blockingArm{moving: make(chan struct{}), stopped: make(chan struct{})}
+	aps := &ArmPositionSaver{
+		cfg:   &ArmPositionSaverConfig{Arm: "arm", Joints: []float64{1, 2}},
+		mover: &armMover{armName: "arm", arm: a, logger: logging.NewTestLogger(t)},
+	}
+	at := &ArmTrajectory{
+		cfg:       &ArmTrajectoryConfig{Segments: []TrajectorySegment{{Position: "a"}}},
+		logger:    logging.NewTestLogger(t),
+		positions: []toggleswitch.Switch{aps},
+	}
+
+	_, err := at.DoCommand(ctx, map[string]interface{}{"start": true})
+	test.That(t, err, test.ShouldBeNil)
+	<-a.moving
+
+	cctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	_, err = at.DoCommand(cctx, map[string]interface{}{"cancel": true})
+	test.That(t, err, test.ShouldBeNil)
+
+	select {
+	case <-a.stopped:
+	default:
+		t.Fatal("arm wasn't stopped")
+	}
+}
+
+func TestArmTrajectorySpeedWithMotion(t *testing.T) {
+	ctx := context.Background()
+
+	// with motion and a pose the saver plans by default, which can't limit joint speeds,
+	// so a segment with speeds tells it to move the joints
+	ms := &fakeMotionService{pose: spatialmath.NewPoseFromPoint(r3.Vector{Z: 500})}
+	a := &fakeArm{}
+	aps := &ArmPositionSaver{
+		cfg: &ArmPositionSaverConfig{
+			Arm:         "arm",
+			Motion:      "builtin",
+			Joints:      []float64{1, 2},
+			Point:       r3.Vector{X: 100},
+			Orientation: spatialmath.OrientationVectorDegrees{OZ: -1},
+		},
+		mover: &armMover{armName: "arm", arm: a, motion: ms, logger: logging.NewTestLogger(t)},
+	}
+	at := &ArmTrajectory{
+		cfg:       &ArmTrajectoryConfig{Segments: []TrajectorySegment{{Position: "a", MaxVelDegsPerSec: 10}}},
+		logger:    logging.NewTestLogger(t),
+		positions: []toggleswitch.Switch{aps},
+	}
+
+	err := at.SetPosition(ctx, 1, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(ms.moves), test.ShouldEqual, 0)
+	test.That(t, a.joints, test.ShouldResemble, []referenceframe.Input{1, 2})
+	test.That(t, a.options, test.ShouldNotBeNil)
+	test.That(t, a.options.MaxVelRads, test.ShouldAlmostEqual, 10*math.Pi/180)
+
+	// without speeds it plans as usual
+	at.cfg.Segments[0].MaxVelDegsPerSec = 0
+	err = at.SetPosition(ctx, 1, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(ms.moves), test.ShouldEqual, 1)
+
+	// asking the saver directly to plan with speeds is still an error
+	err = aps.SetPosition(ctx, 2, map[string]interface{}{"max_vel_degs_per_sec": 10.0})
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "only limit joint moves")
+}
+
+func TestMoveOptionsFromExtra(t *testing.T) {
+	opts, extra := moveOptionsFromExtra(map[string]interface{}{"a": 1}, nil)
+	test.That(t, opts, test.ShouldBeNil)
+	test.That(t, extra, test.ShouldResemble, map[string]interface{}{"a": 1})
+
+	opts, extra = moveOptionsFromExtra(map[string]interface{}{"a": 1, "max_vel_degs_per_sec": 1.0}, map[string]interface{}{"max_vel_degs_per_sec": 180.0})
+	test.That(t, opts.MaxVelRads, test.ShouldAlmostEqual, math.Pi)
+	test.That(t, opts.MaxAccRads, test.ShouldEqual, 0)
+	test.That(t, extra, test.ShouldResemble, map[string]interface{}{"a": 1})
+
+	opts, extra = moveOptionsFromExtra(nil, nil)
+	test.That(t, opts, test.ShouldBeNil)
+	test.That(t, extra, test.ShouldBeNil)
+
+	// arm-position-saver uses them for joint moves
+	a := &fakeArm{}
+	aps := &ArmPositionSaver{
+		cfg:   &ArmPositionSaverConfig{Arm: "arm", Joints: []float64{1, 2}},
+		mover: &armMover{armName: "arm", arm: a, logger: logging.NewTestLogger(t)},
+	}
+	err := aps.SetPosition(context.Background(), 2, map[string]interface{}{"max_acc_degs_per_sec2": 90.0})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, a.joints, test.ShouldResemble, []referenceframe.Input{1, 2})
+	test.That(t, a.options.MaxAccRads, test.ShouldAlmostEqual, math.Pi/2)
+}
@@ -0,0 +1,139 @@
+package touch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/utils"
+
+	"github.com/erh/vmodutils"
+)
+
+// AttributeStore is where a component keeps attributes it changes itself, like a taught position.
+type AttributeStore interface {
+	// Load returns what was saved, which replaces the configured attributes with the same names. nil if nothing was saved.
+	Load(ctx context.Context) (utils.AttributeMap, error)
+	// Save saves values, which replace the attributes with the same names.
+	Save(ctx context.Context, values utils.AttributeMap) error
+}
+
+// PersistenceConfig picks an AttributeStore, nil means cloud.
+type PersistenceConfig struct {
+	Type string `json:"type"`           // cloud (default) or file
+	Path string `json:"path,omitempty"` // for file, defaults to <name>.json in the module's data directory
+}
+
+func (c *PersistenceConfig) Validate() error {
+	if c == nil {
+		return nil
+	}
+	switch c.Type {
+	case "", "cloud":
+		if c.Path != "" {
+			return fmt.Errorf("persistence path is only used with file persistence")
+		}
+	case "file":
+	default:
+		return fmt.Errorf("unknown persistence type [%s], has to be cloud or file", c.Type)
+	}
+	return nil
+}
+
+// NewAttributeStore makes the store c picks for the component config is for.
+func (c *PersistenceConfig) NewAttributeStore(config resource.Config, logger logging.Logger) (AttributeStore, error) {
+	if c == nil || c.Type != "file" {
+		return &cloudAttributeStore{name: config.ResourceName(), base: config.Attributes, logger: logger}, nil
+	}
+
+	path := c.Path
+	if path == "" {
+		dir := os.Getenv("VIAM_MODULE_DATA")
+		if dir == "" {
+			return nil, fmt.Errorf("need a persistence path, there is no module data directory")
+		}
+		path = filepath.Join(dir, config.ResourceName().ShortName()+".json")
+	}
+	return &fileAttributeStore{path: path}, nil
+}
+
+// cloudAttributeStore saves to the machine's config in the cloud, which is also where the values are loaded from.
+type cloudAttributeStore struct {
+	name   resource.Name
+	base   utils.AttributeMap // the attributes the values are saved over
+	logger logging.Logger
+}
+
+func (s *cloudAttributeStore) Load(ctx context.Context) (utils.AttributeMap, error) {
+	return nil, nil
+}
+
+func (s *cloudAttributeStore) Save(ctx context.Context, values utils.AttributeMap) error {
+	// so structs in values are plain json in the config
+	values, err := vmodutils.ToAttributeMap(values)
+	if err != nil {
+		return err
+	}
+
+	attrs := utils.AttributeMap{}
+	maps.Copy(attrs, s.base)
+	maps.Copy(attrs, values)
+
+	err = vmodutils.UpdateComponentCloudAttributesFromModuleEnv(ctx, s.name, attrs, s.logger)
+	if err != nil {
+		return err
+	}
+	s.base = attrs
+	return nil
+}
+
+// fileAttributeStore saves to a local json file, for machines that are offline or configured locally.
+type fileAttributeStore struct {
+	path string
+}
+
+func (s *fileAttributeStore) Load(ctx context.Context) (utils.AttributeMap, error) {
+	attrs := utils.AttributeMap{}
+	err := vmodutils.ReadJSONFromFile(s.path, &attrs)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot read saved attributes from %s: %w", s.path, err)
+	}
+	return attrs, nil
+}
+
+func (s *fileAttributeStore) Save(ctx context.Context, values utils.AttributeMap) error {
+	attrs, err := s.Load(ctx)
+	if err != nil {
+		return err
+	}
+	if attrs == nil {
+		attrs = utils.AttributeMap{}
+	}
+	maps.Copy(attrs, values)
+
+	return vmodutils.WriteJSONToFile(s.path, attrs)
+}
+
+// loadSavedConfig returns attrs with what store saved on top of them, as a config.
+func loadSavedConfig[T any](ctx context.Context, store AttributeStore, attrs utils.AttributeMap) (T, error) {
+	var zero T
+
+	saved, err := store.Load(ctx)
+	if err != nil {
+		return zero, err
+	}
+
+	merged := utils.AttributeMap{}
+	maps.Copy(merged, attrs)
+	maps.Copy(merged, saved)
+
+	return resource.TransformAttributeMap[T](merged)
+}
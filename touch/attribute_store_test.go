@@ -0,0 +1,94 @@
+package touch
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/referenceframe"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/spatialmath"
+	"go.viam.com/rdk/utils"
+	"go.viam.com/test"
+)
+
+func TestPersistenceConfig(t *testing.T) {
+	var c *PersistenceConfig
+	test.That(t, c.Validate(), test.ShouldBeNil)
+	test.That(t, (&PersistenceConfig{Type: "file"}).Validate(), test.ShouldBeNil)
+	test.That(t, (&PersistenceConfig{Type: "cloud", Path: "x"}).Validate(), test.ShouldNotBeNil)
+	test.That(t, (&PersistenceConfig{Type: "disk"}).Validate(), test.ShouldNotBeNil)
+
+	config := resource.Config{Name: "saver"}
+	logger := logging.NewTestLogger(t)
+
+	s, err := c.NewAttributeStore(config, logger)
+	test.That(t, err, test.ShouldBeNil)
+	_, ok := s.(*cloudAttributeStore)
+	test.That(t, ok, test.ShouldBeTrue)
+
+	t.Setenv("VIAM_MODULE_DATA", "")
+	_, err = (&PersistenceConfig{Type: "file"}).NewAttributeStore(config, logger)
+	test.That(t, err, test.ShouldNotBeNil)
+
+	dir := t.TempDir()
+	t.Setenv("VIAM_MODULE_DATA", dir)
+	s, err = (&PersistenceConfig{Type: "file"}).NewAttributeStore(config, logger)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, s.(*fileAttributeStore).path, test.ShouldEqual, filepath.Join(dir, "saver.json"))
+}
+
+func TestFileAttributeStore(t *testing.T) {
+	ctx := context.Background()
+	s := &fileAttributeStore{path: filepath.Join(t.TempDir(), "a.json")}
+
+	attrs, err := s.Load(ctx)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, attrs, test.ShouldBeNil)
+
+	test.That(t, s.Save(ctx, utils.AttributeMap{"joints": []float64{1, 2}, "x": "a"}), test.ShouldBeNil)
+	test.That(t, s.Save(ctx, utils.AttributeMap{"joints": []float64{3}}), test.ShouldBeNil)
+
+	attrs, err = s.Load(ctx)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, attrs["joints"], test.ShouldResemble, []interface{}{3.0})
+	test.That(t, attrs["x"], test.ShouldEqual, "a")
+
+	// saved values win over the config
+	cfg, err := loadSavedConfig[*ArmPositionSaverConfig](ctx, s, utils.AttributeMap{"arm": "arm", "joints": []float64{5}})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, cfg.Arm, test.ShouldEqual, "arm")
+	test.That(t, cfg.Joints, test.ShouldResemble, []float64{3})
+}
+
+func TestArmPositionSaverFilePersistence(t *testing.T) {
+	ctx := context.Background()
+	s := &fileAttributeStore{path: filepath.Join(t.TempDir(), "saver.json")}
+	attrs := utils.AttributeMap{"arm": "arm", "motion": "builtin", "extra": map[string]interface{}{"a": 1}}
+
+	a := &fakeArm{joints: []referenceframe.Input{1, 2}}
+	ms := &fakeMotionService{pose: spatialmath.NewPose(r3.Vector{X: 10, Y: 20, Z: 30}, &spatialmath.OrientationVectorDegrees{OZ: -1})}
+
+	cfg, err := loadSavedConfig[*ArmPositionSaverConfig](ctx, s, attrs)
+	test.That(t, err, test.ShouldBeNil)
+	aps := &ArmPositionSaver{
+		cfg:    cfg,
+		store:  s,
+		logger: logging.NewTestLogger(t),
+		mover:  &armMover{armName: "arm", arm: a, motion: ms, logger: logging.NewTestLogger(t)},
+	}
+
+	test.That(t, aps.SetPosition(ctx, 1, nil), test.ShouldBeNil)
+	test.That(t, aps.config().Point, test.ShouldResemble, r3.Vector{X: 10, Y: 20, Z: 30})
+
+	// what a new one loads
+	cfg, err = loadSavedConfig[*ArmPositionSaverConfig](ctx, s, attrs)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, cfg.Point, test.ShouldResemble, r3.Vector{X: 10, Y: 20, Z: 30})
+	test.That(t, cfg.Orientation.OZ, test.ShouldAlmostEqual, -1)
+	test.That(t, cfg.Joints, test.ShouldResemble, []float64{1, 2})
+	test.That(t, cfg.Extra["a"], test.ShouldEqual, 1)
+}
@@ -0,0 +1,173 @@
+package touch
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/referenceframe"
+	"go.viam.com/rdk/spatialmath"
+)
+
+// CropRegion is a set of geometries in a frame.
+// Each geometry's translation and orientation place it within the frame.
+type CropRegion struct {
+	Frame      string                       `json:"frame,omitempty"` // defaults to world
+	Geometries []spatialmath.GeometryConfig `json:"geometries"`
+}
+
+func (r *CropRegion) frame() string {
+	if r.Frame == "" {
+		return referenceframe.World
+	}
+	return r.Frame
+}
+
+func (r *CropRegion) ParseGeometries() ([]spatialmath.Geometry, error) {
+	if len(r.Geometries) == 0 {
+		return nil, fmt.Errorf("crop region needs geometries")
+	}
+
+	gs := []spatialmath.Geometry{}
+	for _, gc := range r.Geometries {
+		g, err := gc.ParseConfig()
+		if err != nil {
+			return nil, err
+		}
+		gs = append(gs, g)
+	}
+	return gs, nil
+}
+
+// PoseTransformer is the part of the frame system needed to place a CropRegion in the world.
+type PoseTransformer interface {
+	TransformPose(ctx context.Context, pose *referenceframe.PoseInFrame, dst string, supplementalTransforms []*referenceframe.LinkInFrame) (*referenceframe.PoseInFrame, error)
+}
+
+// WorldGeometries returns the region's geometries in the world frame.
+func (r *CropRegion) WorldGeometries(ctx context.Context, fs PoseTransformer) ([]spatialmath.Geometry, error) {
+	gs, err := r.ParseGeometries()
+	if err != nil {
+		return nil, err
+	}
+
+	if r.frame() == referenceframe.World {
+		return gs, nil
+	}
+
+	framePose, err := fs.TransformPose(ctx, referenceframe.NewPoseInFrame(r.frame(), spatialmath.NewZeroPose()), referenceframe.World, nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot find crop region frame [%s]: %w", r.frame(), err)
+	}
+
+	for i, g := range gs {
+		gs[i] = g.Transform(framePose.Pose())
+	}
+	return gs, nil
+}
+
+func cropRegionsToWorld(ctx context.Context, fs PoseTransformer, regions []CropRegion) ([]spatialmath.Geometry, error) {
+	all := []spatialmath.Geometry{}
+	for _, r := range regions {
+		gs, err := r.WorldGeometries(ctx, fs)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, gs...)
+	}
+	return all, nil
+}
+
+// geometryContainer checks points against a geometry, boxes, spheres, and capsules are checked directly,
+// anything else goes through CollidesWith.
+type geometryContainer struct {
+	g   spatialmath.Geometry
+	cfg *spatialmath.GeometryConfig
+
+	center r3.Vector
+	axes   [3]r3.Vector // the geometry's x, y, and z axes in world
+}
+
+func newGeometryContainer(g spatialmath.Geometry) geometryContainer {
+	gc := geometryContainer{g: g}
+	cfg, err := spatialmath.NewGeometryConfig(g)
+	if err == nil {
+		gc.cfg = cfg
+		gc.center = g.Pose().Point()
+		rotation := spatialmath.NewPoseFromOrientation(g.Pose().Orientation())
+		for i, unit := range []r3.Vector{{X: 1}, {Y: 1}, {Z: 1}} {
+			gc.axes[i] = spatialmath.Compose(rotation, spatialmath.NewPoseFromPoint(unit)).Point()
+		}
+	}
+	return gc
+}
+
+func (gc geometryContainer) contains(p r3.Vector) bool {
+	if gc.cfg == nil {
+		in, err := gc.g.CollidesWith(spatialmath.NewPoint(p, ""), 0)
+		return err == nil && in
+	}
+
+	d := p.Sub(gc.center)
+	local := r3.Vector{X: gc.axes[0].Dot(d), Y: gc.axes[1].Dot(d), Z: gc.axes[2].Dot(d)}
+
+	switch gc.cfg.Type {
+	case spatialmath.BoxType:
+		return math.Abs(local.X) <= gc.cfg.X/2 && math.Abs(local.Y) <= gc.cfg.Y/2 && math.Abs(local.Z) <= gc.cfg.Z/2
+	case spatialmath.SphereType:
+		return local.Norm() <= gc.cfg.R
+	case spatialmath.CapsuleType:
+		// capsules run along z, L is tip to tip
+		half := gc.cfg.L/2 - gc.cfg.R
+		z := math.Max(-half, math.Min(half, local.Z))
+		return local.Sub(r3.Vector{Z: z}).Norm() <= gc.cfg.R
+	case spatialmath.PointType:
+		return local.Norm() < 1e-6
+	}
+
+	return false
+}
+
+// PCCropWithGeometries keeps points that are inside any of include and outside all of exclude.
+// If include is empty every point not excluded is kept.
+func PCCropWithGeometries(pc pointcloud.PointCloud, include, exclude []spatialmath.Geometry) pointcloud.PointCloud {
+	in := []geometryContainer{}
+	for _, g := range include {
+		in = append(in, newGeometryContainer(g))
+	}
+	out := []geometryContainer{}
+	for _, g := range exclude {
+		out = append(out, newGeometryContainer(g))
+	}
+
+	fixed := pointcloud.NewBasicEmpty()
+
+	pc.Iterate(0, 0, func(p r3.Vector, d pointcloud.Data) bool {
+		if len(in) > 0 {
+			good := false
+			for _, c := range in {
+				if c.contains(p) {
+					good = true
+					break
+				}
+			}
+			if !good {
+				return true
+			}
+		}
+
+		for _, c := range out {
+			if c.contains(p) {
+				return true
+			}
+		}
+
+		fixed.Set(p, d)
+		return true
+	})
+
+	return fixed
+}
@@ -0,0 +1,164 @@
+package touch
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/referenceframe"
+	"go.viam.com/rdk/spatialmath"
+	"go.viam.com/test"
+)
+
+func makeGridCloud(min, max int) pointcloud.PointCloud {
+	pc := pointcloud.NewBasicEmpty()
+	for x := min; x <= max; x++ {
+		for y := min; y <= max; y++ {
+			for z := min; z <= max; z++ {
+				pc.Set(r3.Vector{X: float64(x), Y: float64(y), Z: float64(z)}, pointcloud.NewBasicData())
+			}
+		}
+	}
+	return pc
+}
+
+func TestPCCropWithGeometries(t *testing.T) {
+	pc := makeGridCloud(-10, 10)
+
+	// box rotated 45 degrees around z
+	box, err := spatialmath.NewBox(
+		spatialmath.NewPose(r3.Vector{}, &spatialmath.OrientationVectorDegrees{OZ: 1, Theta: 45}),
+		r3.Vector{X: 10, Y: 2, Z: 2},
+		"",
+	)
+	test.That(t, err, test.ShouldBeNil)
+
+	out := PCCropWithGeometries(pc, []spatialmath.Geometry{box}, nil)
+	_, got := out.At(3, 3, 0)
+	test.That(t, got, test.ShouldBeTrue)
+	_, got = out.At(-3, -3, 1)
+	test.That(t, got, test.ShouldBeTrue)
+	_, got = out.At(3, -3, 0)
+	test.That(t, got, test.ShouldBeFalse)
+	_, got = out.At(4, 0, 0)
+	test.That(t, got, test.ShouldBeFalse)
+
+	sphere, err := spatialmath.NewSphere(spatialmath.NewPoseFromPoint(r3.Vector{X: 5}), 2, "")
+	test.That(t, err, test.ShouldBeNil)
+
+	out = PCCropWithGeometries(pc, []spatialmath.Geometry{box, sphere}, nil)
+	_, got = out.At(7, 0, 0)
+	test.That(t, got, test.ShouldBeTrue)
+	_, got = out.At(3, 3, 0)
+	test.That(t, got, test.ShouldBeTrue)
+
+	// capsule along x, 10 tip to tip
+	capsule, err := spatialmath.NewCapsule(
+		spatialmath.NewPose(r3.Vector{}, &spatialmath.OrientationVectorDegrees{OX: 1}),
+		1.5, 10, "",
+	)
+	test.That(t, err, test.ShouldBeNil)
+
+	out = PCCropWithGeometries(pc, []spatialmath.Geometry{capsule}, nil)
+	_, got = out.At(0, 1, 1)
+	test.That(t, got, test.ShouldBeTrue)
+	_, got = out.At(4, 1, 0)
+	test.That(t, got, test.ShouldBeTrue)
+	_, got = out.At(5, 1, 0)
+	test.That(t, got, test.ShouldBeFalse)
+	_, got = out.At(0, 2, 0)
+	test.That(t, got, test.ShouldBeFalse)
+	_, got = out.At(-6, 0, 0)
+	test.That(t, got, test.ShouldBeFalse)
+
+	// exclude a sphere from everything
+	out = PCCropWithGeometries(pc, nil, []spatialmath.Geometry{sphere})
+	test.That(t, out.Size(), test.ShouldEqual, pc.Size()-33)
+	_, got = out.At(5, 0, 0)
+	test.That(t, got, test.ShouldBeFalse)
+
+	// include the box except for a hole
+	hole, err := spatialmath.NewSphere(spatialmath.NewPoseFromPoint(r3.Vector{X: 3, Y: 3}), 1, "")
+	test.That(t, err, test.ShouldBeNil)
+
+	out = PCCropWithGeometries(pc, []spatialmath.Geometry{box}, []spatialmath.Geometry{hole})
+	_, got = out.At(3, 3, 0)
+	test.That(t, got, test.ShouldBeFalse)
+	_, got = out.At(1, 1, 0)
+	test.That(t, got, test.ShouldBeTrue)
+}
+
+type fakePoseTransformer struct {
+	frames map[string]spatialmath.Pose
+}
+
+func (f *fakePoseTransformer) TransformPose(ctx context.Context, pose *referenceframe.PoseInFrame, dst string, _ []*referenceframe.LinkInFrame) (*referenceframe.PoseInFrame, error) {
+	p, ok := f.frames[pose.Parent()]
+	if !ok {
+		return nil, referenceframe.NewFrameMissingError(pose.Parent())
+	}
+	return referenceframe.NewPoseInFrame(dst, spatialmath.Compose(p, pose.Pose())), nil
+}
+
+func TestCropRegionWorldGeometries(t *testing.T) {
+	fs := &fakePoseTransformer{frames: map[string]spatialmath.Pose{
+		"bin": spatialmath.NewPose(r3.Vector{X: 100}, &spatialmath.OrientationVectorDegrees{OZ: 1, Theta: 90}),
+	}}
+
+	r := CropRegion{
+		Frame: "bin",
+		Geometries: []spatialmath.GeometryConfig{
+			{Type: spatialmath.BoxType, X: 20, Y: 2, Z: 2, TranslationOffset: r3.Vector{X: 10}},
+		},
+	}
+
+	gs, err := r.WorldGeometries(context.Background(), fs)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(gs), test.ShouldEqual, 1)
+
+	// the box runs along the bin's x, which is world y
+	c := newGeometryContainer(gs[0])
+	test.That(t, c.contains(r3.Vector{X: 100, Y: 15}), test.ShouldBeTrue)
+	test.That(t, c.contains(r3.Vector{X: 100, Y: -5}), test.ShouldBeFalse)
+	test.That(t, c.contains(r3.Vector{X: 100, Y: -15}), test.ShouldBeFalse)
+	test.That(t, c.contains(r3.Vector{X: 110}), test.ShouldBeFalse)
+
+	r.Frame = "missing"
+	_, err = r.WorldGeometries(context.Background(), fs)
+	test.That(t, err, test.ShouldNotBeNil)
+
+	r.Frame = ""
+	gs, err = r.WorldGeometries(context.Background(), nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, newGeometryContainer(gs[0]).contains(r3.Vector{X: 15}), test.ShouldBeTrue)
+
+	_, err = (&CropRegion{}).ParseGeometries()
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func TestCropCameraConfigCropBox(t *testing.T) {
+	ccc := &CropCameraConfig{Src: "a", Min: r3.Vector{X: 1}, Max: r3.Vector{X: 2}}
+	min, max := ccc.cropBox()
+	test.That(t, min, test.ShouldResemble, ccc.Min)
+	test.That(t, max, test.ShouldResemble, ccc.Max)
+
+	ccc = &CropCameraConfig{Src: "a", Include: []CropRegion{{Geometries: []spatialmath.GeometryConfig{{Type: spatialmath.SphereType, R: 5}}}}}
+	_, _, err := ccc.Validate("")
+	test.That(t, err, test.ShouldBeNil)
+
+	min, max = ccc.cropBox()
+	test.That(t, InBox(r3.Vector{X: 1e9, Y: -1e9}, min, max), test.ShouldBeTrue)
+
+	ccc.Exclude = []CropRegion{{}}
+	_, _, err = ccc.Validate("")
+	test.That(t, err, test.ShouldNotBeNil)
+
+	// Include's spare capacity is never written to
+	include := make([]CropRegion, 1, 2)
+	include[0] = CropRegion{Frame: "a"}
+	ccc = &CropCameraConfig{Src: "a", Include: include, Exclude: []CropRegion{{Frame: "b"}}}
+	test.That(t, ccc.frameNames(), test.ShouldResemble, []string{"a", "b"})
+	test.That(t, include[:2][1].Frame, test.ShouldEqual, "")
+}
@@ -2,6 +2,7 @@ package touch
 
 import (
 	"fmt"
+	"math"
 	"slices"
 	"strings"
 
@@ -130,6 +131,14 @@ func HashInputs(in []referenceframe.Input) int {
 	return hash
 }
 
+// inputsClose is true if every input in a is within .1 of the one in b, the resolution HashInputs uses,
+// so encoder jitter doesn't count as a move.
+func inputsClose(a, b []referenceframe.Input) bool {
+	return slices.EqualFunc(a, b, func(x, y referenceframe.Input) bool {
+		return math.Abs(x-y) < .1
+	})
+}
+
 func HashWorldState(ws *referenceframe.WorldState) int {
 	if ws == nil {
 		return 0
@@ -21,15 +21,21 @@ func TestHashString(t *testing.T) {
 }
 
 func TestHashInputs(t *testing.T) {
-	a := []referenceframe.Input{{5}, {7}}
-	b := []referenceframe.Input{{5.0001}, {7.0001}}
-	c := []referenceframe.Input{{5.1}, {7.1}}
-	d := []referenceframe.Input{{7}, {5}}
+	a := []referenceframe.Input{5, 7}
+	b := []referenceframe.Input{5.0001, 7.0001}
+	c := []referenceframe.Input{5.1, 7.1}
+	d := []referenceframe.Input{7, 5}
 
 	test.That(t, HashInputs(a), test.ShouldEqual, HashInputs(a))
 	test.That(t, HashInputs(a), test.ShouldEqual, HashInputs(b))
 	test.That(t, HashInputs(a), test.ShouldNotEqual, HashInputs(c))
 	test.That(t, HashInputs(a), test.ShouldNotEqual, HashInputs(d))
+
+	test.That(t, inputsClose(a, b), test.ShouldBeTrue)
+	test.That(t, inputsClose(a, []referenceframe.Input{4.9999, 6.9999}), test.ShouldBeTrue)
+	test.That(t, inputsClose(a, []referenceframe.Input{5.2, 7}), test.ShouldBeFalse)
+	test.That(t, inputsClose(a, d), test.ShouldBeFalse)
+	test.That(t, inputsClose(a, a[:1]), test.ShouldBeFalse)
 }
 
 func TestHashPose(t *testing.T) {
@@ -3,9 +3,13 @@ package touch
 import (
 	"context"
 	"fmt"
+	"slices"
+	"sync"
 
+	"go.viam.com/rdk/pointcloud"
 	"go.viam.com/rdk/referenceframe"
 	"go.viam.com/rdk/robot/framesystem"
+	"go.viam.com/rdk/spatialmath"
 )
 
 func FrameSystemWithSomeParts(ctx context.Context, myRobot framesystem.Service, names []string, transforms []*referenceframe.LinkInFrame) (*referenceframe.FrameSystem, error) {
@@ -43,3 +47,146 @@ func FindPart(fsc *framesystem.Config, name string) *referenceframe.FrameSystemP
 	}
 	return nil
 }
+
+// LocalFrameTransformer transforms poses and point clouds to world locally using the parts of the frame system they need.
+// The frame system is only fetched again when the set of frames changes, and poses are only recomputed
+// when the inputs (i.e. arm joints) of something in those parts change.
+type LocalFrameTransformer struct {
+	fs framesystem.Service
+
+	lock   sync.Mutex
+	names  []string
+	frames *referenceframe.FrameSystem
+	inputs referenceframe.FrameSystemInputs
+	poses  map[string]spatialmath.Pose
+}
+
+func NewLocalFrameTransformer(fs framesystem.Service) *LocalFrameTransformer {
+	return &LocalFrameTransformer{fs: fs}
+}
+
+// Update makes sure names are in the frame system and the inputs are current.
+func (lft *LocalFrameTransformer) Update(ctx context.Context, names []string) error {
+	lft.lock.Lock()
+	defer lft.lock.Unlock()
+
+	if lft.frames == nil || !slices.Equal(names, lft.names) {
+		frames, err := FrameSystemWithSomeParts(ctx, lft.fs, names, nil)
+		if err != nil {
+			return err
+		}
+		lft.frames = frames
+		lft.names = slices.Clone(names)
+		lft.inputs = referenceframe.NewZeroInputs(frames)
+		lft.poses = map[string]spatialmath.Pose{}
+	}
+
+	hasInputs := false
+	for _, in := range lft.inputs {
+		if len(in) > 0 {
+			hasInputs = true
+			break
+		}
+	}
+	if !hasInputs {
+		return nil
+	}
+
+	all, err := lft.fs.CurrentInputs(ctx)
+	if err != nil {
+		return err
+	}
+
+	changed := false
+	for name, old := range lft.inputs {
+		if len(old) == 0 {
+			continue
+		}
+		in, ok := all[name]
+		if !ok {
+			return fmt.Errorf("no inputs for [%s]", name)
+		}
+		if !inputsClose(in, old) {
+			lft.inputs[name] = in
+			changed = true
+		}
+	}
+
+	if changed {
+		lft.poses = map[string]spatialmath.Pose{}
+	}
+
+	return nil
+}
+
+// WorldPose returns the pose of frame name in world as of the last Update.
+func (lft *LocalFrameTransformer) WorldPose(name string) (spatialmath.Pose, error) {
+	if name == referenceframe.World {
+		return spatialmath.NewZeroPose(), nil
+	}
+
+	lft.lock.Lock()
+	defer lft.lock.Unlock()
+
+	if lft.frames == nil {
+		return nil, fmt.Errorf("LocalFrameTransformer needs Update before WorldPose")
+	}
+
+	p, ok := lft.poses[name]
+	if ok {
+		return p, nil
+	}
+
+	tf, err := lft.frames.Transform(lft.inputs, referenceframe.NewPoseInFrame(name, spatialmath.NewZeroPose()), referenceframe.World)
+	if err != nil {
+		return nil, err
+	}
+	p = tf.(*referenceframe.PoseInFrame).Pose()
+	lft.poses[name] = p
+	return p, nil
+}
+
+// TransformPose only supports transforming to world, so it can be used for CropRegion.
+func (lft *LocalFrameTransformer) TransformPose(ctx context.Context, pose *referenceframe.PoseInFrame, dst string, supplementalTransforms []*referenceframe.LinkInFrame) (*referenceframe.PoseInFrame, error) {
+	if dst != referenceframe.World || len(supplementalTransforms) > 0 {
+		return nil, fmt.Errorf("LocalFrameTransformer can only transform to world")
+	}
+	p, err := lft.WorldPose(pose.Parent())
+	if err != nil {
+		return nil, err
+	}
+	return referenceframe.NewPoseInFrame(dst, spatialmath.Compose(p, pose.Pose())), nil
+}
+
+// RelativePose returns the pose of frame src in frame dst as of the last Update.
+func (lft *LocalFrameTransformer) RelativePose(src, dst string) (spatialmath.Pose, error) {
+	s, err := lft.WorldPose(src)
+	if err != nil {
+		return nil, err
+	}
+	if dst == referenceframe.World {
+		return s, nil
+	}
+	d, err := lft.WorldPose(dst)
+	if err != nil {
+		return nil, err
+	}
+	return spatialmath.PoseBetween(d, s), nil
+}
+
+// TransformPointCloud moves pc from frame src to frame dst.
+func (lft *LocalFrameTransformer) TransformPointCloud(pc pointcloud.PointCloud, src, dst string) (pointcloud.PointCloud, error) {
+	p, err := lft.RelativePose(src, dst)
+	if err != nil {
+		return nil, err
+	}
+	if spatialmath.PoseAlmostEqual(p, spatialmath.NewZeroPose()) {
+		return pc, nil
+	}
+	out := pc.CreateNewRecentered(p)
+	err = pointcloud.ApplyOffset(pc, p, out)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
@@ -0,0 +1,158 @@
+package touch
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/referenceframe"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/robot/framesystem"
+	"go.viam.com/rdk/spatialmath"
+	"go.viam.com/test"
+)
+
+type fakeFrameSystemService struct {
+	resource.Named
+	resource.TriviallyReconfigurable
+	resource.TriviallyCloseable
+
+	parts  []*referenceframe.FrameSystemPart
+	inputs referenceframe.FrameSystemInputs
+
+	configCalls int
+	inputCalls  int
+}
+
+func (f *fakeFrameSystemService) DoCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	return nil, nil
+}
+
+func (f *fakeFrameSystemService) FrameSystemConfig(ctx context.Context) (*framesystem.Config, error) {
+	f.configCalls++
+	return &framesystem.Config{Parts: f.parts}, nil
+}
+
+func (f *fakeFrameSystemService) GetPose(ctx context.Context, componentName, destinationFrame string, supplementalTransforms []*referenceframe.LinkInFrame, extra map[string]interface{}) (*referenceframe.PoseInFrame, error) {
+	return nil, nil
+}
+
+func (f *fakeFrameSystemService) TransformPose(ctx context.Context, pose *referenceframe.PoseInFrame, dst string, supplementalTransforms []*referenceframe.LinkInFrame) (*referenceframe.PoseInFrame, error) {
+	return nil, nil
+}
+
+func (f *fakeFrameSystemService) TransformPointCloud(ctx context.Context, srcpc pointcloud.PointCloud, srcName, dstName string) (pointcloud.PointCloud, error) {
+	return nil, nil
+}
+
+func (f *fakeFrameSystemService) CurrentInputs(ctx context.Context) (referenceframe.FrameSystemInputs, error) {
+	f.inputCalls++
+	return f.inputs, nil
+}
+
+// newFakeFrameSystemService has a gantry that moves in x and y, with a camera on it 10 up, and a static bin.
+func newFakeFrameSystemService(t *testing.T) *fakeFrameSystemService {
+	gantry, err := referenceframe.New2DMobileModelFrame("gantry", []referenceframe.Limit{{Min: -1000, Max: 1000}, {Min: -1000, Max: 1000}}, nil)
+	test.That(t, err, test.ShouldBeNil)
+
+	part := func(name, parent string, p r3.Vector) *referenceframe.FrameSystemPart {
+		fsp, err := referenceframe.LinkInFrameToFrameSystemPart(referenceframe.NewLinkInFrame(parent, spatialmath.NewPoseFromPoint(p), name, nil))
+		test.That(t, err, test.ShouldBeNil)
+		return fsp
+	}
+
+	g := part("gantry", referenceframe.World, r3.Vector{})
+	g.ModelFrame = gantry
+
+	return &fakeFrameSystemService{
+		Named: framesystem.PublicServiceName.AsNamed(),
+		parts: []*referenceframe.FrameSystemPart{
+			g,
+			part("cam", "gantry", r3.Vector{Z: 10}),
+			part("bin", referenceframe.World, r3.Vector{X: 100}),
+			part("other", referenceframe.World, r3.Vector{Y: 100}),
+		},
+		inputs: referenceframe.FrameSystemInputs{"gantry": {0, 0}},
+	}
+}
+
+func TestLocalFrameTransformer(t *testing.T) {
+	ctx := context.Background()
+	fs := newFakeFrameSystemService(t)
+	lft := NewLocalFrameTransformer(fs)
+
+	_, err := lft.WorldPose("cam")
+	test.That(t, err, test.ShouldNotBeNil)
+
+	// static frames never need inputs
+	err = lft.Update(ctx, []string{"bin"})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, fs.inputCalls, test.ShouldEqual, 0)
+
+	p, err := lft.WorldPose("bin")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, spatialmath.R3VectorAlmostEqual(p.Point(), r3.Vector{X: 100}, 1e-6), test.ShouldBeTrue)
+
+	_, err = lft.WorldPose("other")
+	test.That(t, err, test.ShouldNotBeNil)
+
+	err = lft.Update(ctx, []string{"cam", "bin"})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, fs.configCalls, test.ShouldEqual, 2)
+	test.That(t, fs.inputCalls, test.ShouldEqual, 1)
+
+	p, err = lft.WorldPose("cam")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, spatialmath.R3VectorAlmostEqual(p.Point(), r3.Vector{Z: 10}, 1e-6), test.ShouldBeTrue)
+
+	// same names doesn't refetch the config
+	err = lft.Update(ctx, []string{"cam", "bin"})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, fs.configCalls, test.ShouldEqual, 2)
+	test.That(t, fs.inputCalls, test.ShouldEqual, 2)
+
+	// moving the gantry moves the camera
+	fs.inputs = referenceframe.FrameSystemInputs{"gantry": {5, 7}}
+	err = lft.Update(ctx, []string{"cam", "bin"})
+	test.That(t, err, test.ShouldBeNil)
+
+	p, err = lft.WorldPose("cam")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, spatialmath.R3VectorAlmostEqual(p.Point(), r3.Vector{X: 5, Y: 7, Z: 10}, 1e-6), test.ShouldBeTrue)
+
+	// encoder jitter doesn't drop the cached poses
+	fs.inputs = referenceframe.FrameSystemInputs{"gantry": {5.0001, 6.9999}}
+	err = lft.Update(ctx, []string{"cam", "bin"})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, lft.poses, test.ShouldContainKey, "cam")
+	test.That(t, lft.inputs["gantry"], test.ShouldResemble, []referenceframe.Input{5, 7})
+
+	pc := pointcloud.NewBasicEmpty()
+	test.That(t, pc.Set(r3.Vector{X: 1}, nil), test.ShouldBeNil)
+	out, err := lft.TransformPointCloud(pc, "cam", referenceframe.World)
+	test.That(t, err, test.ShouldBeNil)
+	_, got := out.At(6, 7, 10)
+	test.That(t, got, test.ShouldBeTrue)
+
+	pif, err := lft.TransformPose(ctx, referenceframe.NewPoseInFrame("bin", spatialmath.NewPoseFromPoint(r3.Vector{Z: 1})), referenceframe.World, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, spatialmath.R3VectorAlmostEqual(pif.Pose().Point(), r3.Vector{X: 100, Z: 1}, 1e-6), test.ShouldBeTrue)
+
+	_, err = lft.TransformPose(ctx, pif, "bin", nil)
+	test.That(t, err, test.ShouldNotBeNil)
+
+	err = lft.Update(ctx, []string{"missing"})
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func TestCropCameraConfigFrameNames(t *testing.T) {
+	ccc := &CropCameraConfig{Src: "cam"}
+	test.That(t, ccc.frameNames(), test.ShouldResemble, []string{"cam"})
+
+	ccc.SrcFrame = "cam-frame"
+	ccc.Include = []CropRegion{{Frame: "bin"}, {}}
+	ccc.Exclude = []CropRegion{{Frame: "bin"}, {Frame: "arm"}}
+	test.That(t, ccc.frameNames(), test.ShouldResemble, []string{"cam-frame", "bin", "arm"})
+}
@@ -0,0 +1,308 @@
+package touch
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/spatialmath"
+)
+
+// ICPConfig configures ICPPointToPlane.
+type ICPConfig struct {
+	// MaxCorrespondenceDistance is how far apart (mm) a point and its match in the target can be.
+	MaxCorrespondenceDistance float64 `json:"max_correspondence_distance"`
+	// NormalRadius is the radius used to estimate target normals, defaults to MaxCorrespondenceDistance.
+	NormalRadius float64 `json:"normal_radius"`
+	// Iterations is the most iterations to run, defaults to 30.
+	Iterations int `json:"iterations"`
+	// Tolerance stops early when the residual improves by less than this, defaults to 1e-4.
+	Tolerance float64 `json:"tolerance"`
+}
+
+func (c *ICPConfig) Validate() error {
+	if c.MaxCorrespondenceDistance <= 0 {
+		return fmt.Errorf("icp needs a max_correspondence_distance")
+	}
+	if c.NormalRadius < 0 || c.Iterations < 0 || c.Tolerance < 0 {
+		return fmt.Errorf("icp normal_radius, iterations, and tolerance can't be negative")
+	}
+	return nil
+}
+
+func (c *ICPConfig) normalRadius() float64 {
+	if c.NormalRadius <= 0 {
+		return c.MaxCorrespondenceDistance
+	}
+	return c.NormalRadius
+}
+
+func (c *ICPConfig) iterations() int {
+	if c.Iterations <= 0 {
+		return 30
+	}
+	return c.Iterations
+}
+
+func (c *ICPConfig) tolerance() float64 {
+	if c.Tolerance <= 0 {
+		return 1e-4
+	}
+	return c.Tolerance
+}
+
+// ICPResult is what ICPPointToPlane found.
+type ICPResult struct {
+	// Transform moves the source onto the target.
+	Transform spatialmath.Pose
+	// Residual is the RMS point to plane distance of the matched points after Transform.
+	Residual float64
+	// Matched is how many source points had a match in the target.
+	Matched    int
+	Iterations int
+}
+
+// icpTarget is a target cloud with normals computed as needed.
+type icpTarget struct {
+	g            *voxelGrid
+	normalRadius float64
+
+	normals   []r3.Vector
+	hasNormal []bool
+	computed  []bool
+}
+
+func newICPTarget(pc pointcloud.PointCloud, cfg *ICPConfig) *icpTarget {
+	g := newVoxelGridFromPointCloud(pc, cfg.MaxCorrespondenceDistance)
+	return &icpTarget{
+		g:            g,
+		normalRadius: cfg.normalRadius(),
+		normals:      make([]r3.Vector, len(g.points)),
+		hasNormal:    make([]bool, len(g.points)),
+		computed:     make([]bool, len(g.points)),
+	}
+}
+
+func (t *icpTarget) normal(i int) (r3.Vector, bool) {
+	if !t.computed[i] {
+		t.computed[i] = true
+		ns := t.g.neighbors(t.g.points[i], t.normalRadius)
+		points := make([]r3.Vector, len(ns))
+		for j, n := range ns {
+			points[j] = t.g.points[n]
+		}
+		t.normals[i], _, t.hasNormal[i] = estimateNormal(points)
+	}
+	return t.normals[i], t.hasNormal[i]
+}
+
+// closest returns the index of the closest target point within maxDistance of p, or -1.
+func (t *icpTarget) closest(p r3.Vector, maxDistance float64) int {
+	best := -1
+	bestD := math.Inf(1)
+	t.g.forEachNeighbor(p, maxDistance, func(i int) bool {
+		d := p.Sub(t.g.points[i]).Norm2()
+		if d < bestD {
+			best = i
+			bestD = d
+		}
+		return true
+	})
+	return best
+}
+
+// ICPPointToPlane finds the transform that best lines src up with target by minimizing
+// the distance from each src point to the plane at its closest target point.
+// It assumes src and target are already roughly aligned.
+func ICPPointToPlane(src, target pointcloud.PointCloud, cfg *ICPConfig) (ICPResult, error) {
+	err := cfg.Validate()
+	if err != nil {
+		return ICPResult{}, err
+	}
+
+	res := ICPResult{Transform: spatialmath.NewZeroPose()}
+
+	if src.Size() == 0 || target.Size() == 0 {
+		return res, fmt.Errorf("icp needs points, src has %d and target has %d", src.Size(), target.Size())
+	}
+
+	points := make([]r3.Vector, 0, src.Size())
+	src.Iterate(0, 0, func(p r3.Vector, d pointcloud.Data) bool {
+		points = append(points, p)
+		return true
+	})
+
+	t := newICPTarget(target, cfg)
+
+	lastResidual := math.Inf(1)
+	best := res
+
+	for res.Iterations < cfg.iterations() {
+		res.Iterations++
+
+		// each match adds a row [p x n, n] and value -(p - q) . n to a least squares problem
+		var ata [6][6]float64
+		var atb [6]float64
+		total := 0.0
+		matched := 0
+
+		for _, p0 := range points {
+			p := spatialmath.Compose(res.Transform, spatialmath.NewPoseFromPoint(p0)).Point()
+			q := t.closest(p, cfg.MaxCorrespondenceDistance)
+			if q < 0 {
+				continue
+			}
+			n, ok := t.normal(q)
+			if !ok {
+				continue
+			}
+
+			dist := p.Sub(t.g.points[q]).Dot(n)
+			c := p.Cross(n)
+			row := [6]float64{c.X, c.Y, c.Z, n.X, n.Y, n.Z}
+			for i := 0; i < 6; i++ {
+				for j := 0; j < 6; j++ {
+					ata[i][j] += row[i] * row[j]
+				}
+				atb[i] -= row[i] * dist
+			}
+			total += dist * dist
+			matched++
+		}
+
+		if matched < 6 {
+			return res, fmt.Errorf("icp only matched %d points", matched)
+		}
+
+		residual := math.Sqrt(total / float64(matched))
+		if residual > lastResidual {
+			// the last step made things worse, so keep the one before it
+			break
+		}
+
+		res.Matched = matched
+		res.Residual = residual
+		best = res
+
+		if lastResidual-residual < cfg.tolerance() {
+			break
+		}
+		lastResidual = residual
+
+		x, ok := solve6(ata, atb)
+		if !ok {
+			return res, fmt.Errorf("icp couldn't solve for a transform")
+		}
+
+		step := icpStep(x)
+		res.Transform = spatialmath.Compose(step, res.Transform)
+	}
+
+	best.Iterations = res.Iterations
+	return best, nil
+}
+
+// icpStep turns a small rotation (rotation vector) and translation into a pose.
+func icpStep(x [6]float64) spatialmath.Pose {
+	w := r3.Vector{X: x[0], Y: x[1], Z: x[2]}
+	var o spatialmath.Orientation = spatialmath.NewZeroOrientation()
+	theta := w.Norm()
+	if theta > 1e-12 {
+		axis := w.Mul(1 / theta)
+		o = &spatialmath.R4AA{Theta: theta, RX: axis.X, RY: axis.Y, RZ: axis.Z}
+	}
+	return spatialmath.NewPose(r3.Vector{X: x[3], Y: x[4], Z: x[5]}, o)
+}
+
+// solve6 solves a x = b with gaussian elimination, with a little damping so directions
+// the points don't constrain (like sliding along a single plane) stay put.
+func solve6(a [6][6]float64, b [6]float64) ([6]float64, bool) {
+	trace := 0.0
+	for i := 0; i < 6; i++ {
+		trace += a[i][i]
+	}
+	for i := 0; i < 6; i++ {
+		a[i][i] += 1e-9 * trace / 6
+	}
+
+	for col := 0; col < 6; col++ {
+		pivot := col
+		for r := col + 1; r < 6; r++ {
+			if math.Abs(a[r][col]) > math.Abs(a[pivot][col]) {
+				pivot = r
+			}
+		}
+		if math.Abs(a[pivot][col]) < 1e-15 {
+			return b, false
+		}
+		a[col], a[pivot] = a[pivot], a[col]
+		b[col], b[pivot] = b[pivot], b[col]
+
+		for r := col + 1; r < 6; r++ {
+			f := a[r][col] / a[col][col]
+			for c := col; c < 6; c++ {
+				a[r][c] -= f * a[col][c]
+			}
+			b[r] -= f * b[col]
+		}
+	}
+
+	var x [6]float64
+	for r := 5; r >= 0; r-- {
+		sum := b[r]
+		for c := r + 1; c < 6; c++ {
+			sum -= a[r][c] * x[c]
+		}
+		x[r] = sum / a[r][r]
+	}
+	return x, true
+}
+
+// RefinePointClouds lines each point cloud up with all the ones before it using ICPPointToPlane.
+// The first cloud is never moved. Returns the moved clouds and the result for each, a cloud that
+// couldn't be refined is left where it was and has an error in errs.
+func RefinePointClouds(pcs []pointcloud.PointCloud, cfg *ICPConfig) ([]pointcloud.PointCloud, []ICPResult, []error) {
+	out := make([]pointcloud.PointCloud, len(pcs))
+	results := make([]ICPResult, len(pcs))
+	errs := make([]error, len(pcs))
+
+	if len(pcs) == 0 {
+		return out, results, errs
+	}
+
+	out[0] = pcs[0]
+	results[0] = ICPResult{Transform: spatialmath.NewZeroPose()}
+
+	accumulated := pointcloud.NewBasicEmpty()
+	err := pointcloud.ApplyOffset(pcs[0], nil, accumulated)
+	if err != nil {
+		errs[0] = err
+	}
+
+	for i := 1; i < len(pcs); i++ {
+		out[i] = pcs[i]
+
+		res, err := ICPPointToPlane(pcs[i], accumulated, cfg)
+		results[i] = res
+		if err != nil {
+			errs[i] = err
+		} else {
+			moved := pcs[i].CreateNewRecentered(res.Transform)
+			err = pointcloud.ApplyOffset(pcs[i], res.Transform, moved)
+			if err != nil {
+				errs[i] = err
+			} else {
+				out[i] = moved
+			}
+		}
+
+		err = pointcloud.ApplyOffset(out[i], nil, accumulated)
+		if err != nil {
+			errs[i] = err
+		}
+	}
+
+	return out, results, errs
+}
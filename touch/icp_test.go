@@ -0,0 +1,66 @@
+package touch
+
+import (
+	"testing"
+
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/spatialmath"
+	"go.viam.com/test"
+)
+
+// makeCornerCloud is the floor and two walls of a box corner, which pins down all 6 degrees of freedom.
+func makeCornerCloud(step float64) pointcloud.PointCloud {
+	pc := pointcloud.NewBasicEmpty()
+	for a := 0.0; a < 100; a += step {
+		for b := 0.0; b < 100; b += step {
+			pc.Set(r3.Vector{X: a, Y: b}, pointcloud.NewBasicData())
+			pc.Set(r3.Vector{X: a, Z: b + step}, pointcloud.NewBasicData())
+			pc.Set(r3.Vector{Y: a + step, Z: b + step}, pointcloud.NewBasicData())
+		}
+	}
+	return pc
+}
+
+func TestICPPointToPlane(t *testing.T) {
+	target := makeCornerCloud(2)
+
+	offset := spatialmath.NewPose(r3.Vector{X: 2, Y: -1.5, Z: 1}, &spatialmath.OrientationVectorDegrees{OX: .01, OY: .01, OZ: 1, Theta: 2})
+	src := pointcloud.NewBasicEmpty()
+	test.That(t, pointcloud.ApplyOffset(target, offset, src), test.ShouldBeNil)
+
+	cfg := &ICPConfig{MaxCorrespondenceDistance: 10, NormalRadius: 5}
+	res, err := ICPPointToPlane(src, target, cfg)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, res.Residual, test.ShouldBeLessThan, .1)
+	test.That(t, res.Matched, test.ShouldBeGreaterThan, src.Size()/2)
+
+	// the transform undoes the offset
+	back := spatialmath.Compose(res.Transform, offset)
+	test.That(t, back.Point().Norm(), test.ShouldBeLessThan, .5)
+	test.That(t, spatialmath.QuatToR4AA(back.Orientation().Quaternion()).Theta, test.ShouldBeLessThan, .005)
+
+	_, err = ICPPointToPlane(src, pointcloud.NewBasicEmpty(), cfg)
+	test.That(t, err, test.ShouldNotBeNil)
+	_, err = ICPPointToPlane(src, target, &ICPConfig{})
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func TestRefinePointClouds(t *testing.T) {
+	a := makeCornerCloud(2)
+	b := pointcloud.NewBasicEmpty()
+	test.That(t, pointcloud.ApplyOffset(a, spatialmath.NewPoseFromPoint(r3.Vector{X: 2, Y: 1, Z: -1}), b), test.ShouldBeNil)
+
+	far := pointcloud.NewBasicEmpty()
+	test.That(t, far.Set(r3.Vector{X: 1000}, nil), test.ShouldBeNil)
+
+	out, results, errs := RefinePointClouds([]pointcloud.PointCloud{a, b, far}, &ICPConfig{MaxCorrespondenceDistance: 10, NormalRadius: 5})
+	test.That(t, errs[0], test.ShouldBeNil)
+	test.That(t, errs[1], test.ShouldBeNil)
+	test.That(t, errs[2], test.ShouldNotBeNil)
+
+	test.That(t, results[1].Residual, test.ShouldBeLessThan, .1)
+	test.That(t, out[1].MetaData().MinX, test.ShouldAlmostEqual, 0, .5)
+	test.That(t, out[2], test.ShouldEqual, far)
+}
@@ -0,0 +1,35 @@
+package touch
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/protobuf/encoding/protojson"
+
+	"go.viam.com/rdk/services/motion"
+)
+
+// PlanMove asks ms for a plan for req without moving anything, and returns the trajectory it found.
+// ms has to support the builtin motion service's "plan" DoCommand.
+func PlanMove(ctx context.Context, ms motion.Service, req motion.MoveReq) (interface{}, error) {
+	pb, err := req.ToProto(ms.Name().ShortName())
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := protojson.Marshal(pb)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := ms.DoCommand(ctx, map[string]interface{}{"plan": string(data)})
+	if err != nil {
+		return nil, err
+	}
+
+	plan, ok := res["plan"]
+	if !ok {
+		return nil, fmt.Errorf("motion service [%s] didn't return a plan", ms.Name().ShortName())
+	}
+	return plan, nil
+}
@@ -0,0 +1,99 @@
+package touch
+
+import (
+	"math"
+
+	"github.com/golang/geo/r3"
+)
+
+// estimateNormal fits a plane to points with PCA.
+// Returns the unit normal and the curvature (smallest eigenvalue / sum of eigenvalues).
+// ok is false if there aren't enough points to define a plane.
+func estimateNormal(points []r3.Vector) (r3.Vector, float64, bool) {
+	if len(points) < 3 {
+		return r3.Vector{}, 0, false
+	}
+
+	mean := r3.Vector{}
+	for _, p := range points {
+		mean = mean.Add(p)
+	}
+	mean = mean.Mul(1 / float64(len(points)))
+
+	var cov [3][3]float64
+	for _, p := range points {
+		d := p.Sub(mean)
+		v := [3]float64{d.X, d.Y, d.Z}
+		for i := 0; i < 3; i++ {
+			for j := 0; j < 3; j++ {
+				cov[i][j] += v[i] * v[j]
+			}
+		}
+	}
+
+	values, vectors := symmetricEigen3(cov)
+
+	smallest := 0
+	for i := 1; i < 3; i++ {
+		if values[i] < values[smallest] {
+			smallest = i
+		}
+	}
+
+	sum := values[0] + values[1] + values[2]
+	if sum <= 0 {
+		return r3.Vector{}, 0, false
+	}
+
+	n := r3.Vector{X: vectors[0][smallest], Y: vectors[1][smallest], Z: vectors[2][smallest]}
+	return n.Normalize(), values[smallest] / sum, true
+}
+
+// symmetricEigen3 uses jacobi rotations to get the eigenvalues and eigenvectors (as columns) of a symmetric 3x3 matrix.
+func symmetricEigen3(a [3][3]float64) ([3]float64, [3][3]float64) {
+	v := [3][3]float64{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}}
+
+	for sweep := 0; sweep < 50; sweep++ {
+		off := a[0][1]*a[0][1] + a[0][2]*a[0][2] + a[1][2]*a[1][2]
+		if off < 1e-20 {
+			break
+		}
+
+		for p := 0; p < 2; p++ {
+			for q := p + 1; q < 3; q++ {
+				if math.Abs(a[p][q]) < 1e-30 {
+					continue
+				}
+
+				theta := (a[q][q] - a[p][p]) / (2 * a[p][q])
+				t := 1 / (math.Abs(theta) + math.Sqrt(theta*theta+1))
+				if theta < 0 {
+					t = -t
+				}
+				c := 1 / math.Sqrt(t*t+1)
+				s := t * c
+
+				for k := 0; k < 3; k++ {
+					akp := a[k][p]
+					akq := a[k][q]
+					a[k][p] = c*akp - s*akq
+					a[k][q] = s*akp + c*akq
+				}
+				for k := 0; k < 3; k++ {
+					apk := a[p][k]
+					aqk := a[q][k]
+					a[p][k] = c*apk - s*aqk
+					a[q][k] = s*apk + c*aqk
+				}
+				for k := 0; k < 3; k++ {
+					vkp := v[k][p]
+					vkq := v[k][q]
+					v[k][p] = c*vkp - s*vkq
+					v[k][q] = s*vkp + c*vkq
+				}
+			}
+		}
+	}
+
+	return [3]float64{a[0][0], a[1][1], a[2][2]}, v
+}
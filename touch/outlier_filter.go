@@ -0,0 +1,143 @@
+package touch
+
+import (
+	"fmt"
+	"math"
+
+	"go.viam.com/rdk/pointcloud"
+)
+
+// neighborGridSize guesses a voxel size that puts about k points in each cell,
+// assuming the points are on surfaces like they are from a depth camera.
+func neighborGridSize(pc pointcloud.PointCloud, k int) float64 {
+	md := pc.MetaData()
+	size := md.MaxSideLength() * math.Sqrt(float64(k)/float64(max(pc.Size(), 1)))
+	if size <= 0 {
+		return 1
+	}
+	return size
+}
+
+// PCStatisticalOutlierFilter removes points whose mean distance to their meanK nearest neighbors
+// is more than stdDevThreshold standard deviations above the mean for the whole cloud.
+func PCStatisticalOutlierFilter(pc pointcloud.PointCloud, meanK int, stdDevThreshold float64) (pointcloud.PointCloud, error) {
+	if meanK <= 0 {
+		return nil, fmt.Errorf("meanK has to be positive, not %d", meanK)
+	}
+	if pc.Size() <= meanK {
+		return pc, nil
+	}
+
+	g := newVoxelGridFromPointCloud(pc, neighborGridSize(pc, meanK))
+
+	meanDistances := make([]float64, len(g.points))
+	sum := 0.0
+	for i, p := range g.points {
+		total := 0.0
+		ns := g.kNearest(p, meanK, i)
+		for _, j := range ns {
+			total += p.Distance(g.points[j])
+		}
+		meanDistances[i] = total / float64(len(ns))
+		sum += meanDistances[i]
+	}
+
+	mean := sum / float64(len(meanDistances))
+	variance := 0.0
+	for _, d := range meanDistances {
+		variance += (d - mean) * (d - mean)
+	}
+	stdDev := math.Sqrt(variance / float64(len(meanDistances)))
+
+	limit := mean + stdDevThreshold*stdDev
+
+	out := pointcloud.NewBasicEmpty()
+	for i, d := range meanDistances {
+		if d > limit {
+			continue
+		}
+		err := out.Set(g.points[i], g.data[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return out, nil
+}
+
+// PCRadiusOutlierFilter removes points with fewer than minNeighbors other points within radius.
+func PCRadiusOutlierFilter(pc pointcloud.PointCloud, radius float64, minNeighbors int) (pointcloud.PointCloud, error) {
+	if radius <= 0 {
+		return nil, fmt.Errorf("radius has to be positive, not %v", radius)
+	}
+
+	g := newVoxelGridFromPointCloud(pc, radius)
+
+	out := pointcloud.NewBasicEmpty()
+	for i, p := range g.points {
+		count := 0
+		g.forEachNeighbor(p, radius, func(j int) bool {
+			if j != i {
+				count++
+			}
+			return count < minNeighbors
+		})
+		if count < minNeighbors {
+			continue
+		}
+		err := out.Set(p, g.data[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return out, nil
+}
+
+// StatisticalOutlierConfig configures PCStatisticalOutlierFilter.
+type StatisticalOutlierConfig struct {
+	MeanK  int     `json:"mean_k"`
+	StdDev float64 `json:"std_dev"`
+}
+
+func (c *StatisticalOutlierConfig) Validate() error {
+	if c.MeanK <= 0 {
+		return fmt.Errorf("statistical outlier filter needs a mean_k")
+	}
+	if c.StdDev <= 0 {
+		return fmt.Errorf("statistical outlier filter needs a std_dev")
+	}
+	return nil
+}
+
+// Filter runs the filter, a nil config leaves pc alone.
+func (c *StatisticalOutlierConfig) Filter(pc pointcloud.PointCloud) (pointcloud.PointCloud, error) {
+	if c == nil {
+		return pc, nil
+	}
+	return PCStatisticalOutlierFilter(pc, c.MeanK, c.StdDev)
+}
+
+// RadiusOutlierConfig configures PCRadiusOutlierFilter.
+type RadiusOutlierConfig struct {
+	Radius       float64
+	MinNeighbors int `json:"min_neighbors"`
+}
+
+func (c *RadiusOutlierConfig) Validate() error {
+	if c.Radius <= 0 {
+		return fmt.Errorf("radius outlier filter needs a radius")
+	}
+	if c.MinNeighbors <= 0 {
+		return fmt.Errorf("radius outlier filter needs min_neighbors")
+	}
+	return nil
+}
+
+// Filter runs the filter, a nil config leaves pc alone.
+func (c *RadiusOutlierConfig) Filter(pc pointcloud.PointCloud) (pointcloud.PointCloud, error) {
+	if c == nil {
+		return pc, nil
+	}
+	return PCRadiusOutlierFilter(pc, c.Radius, c.MinNeighbors)
+}
@@ -0,0 +1,106 @@
+package touch
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/test"
+)
+
+// makeNoisyCloud makes a 2mm grid plane plus flying pixels scattered in the space above it.
+func makeNoisyCloud(noise int) (pointcloud.PointCloud, int) {
+	pc := pointcloud.NewBasicEmpty()
+	for x := 0; x < 100; x++ {
+		for y := 0; y < 100; y++ {
+			pc.Set(r3.Vector{X: float64(x * 2), Y: float64(y * 2)}, pointcloud.NewBasicData())
+		}
+	}
+	good := pc.Size()
+
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < noise; i++ {
+		pc.Set(r3.Vector{X: r.Float64() * 200, Y: r.Float64() * 200, Z: 20 + r.Float64()*200}, pointcloud.NewBasicData())
+	}
+
+	return pc, good
+}
+
+func TestPCStatisticalOutlierFilter(t *testing.T) {
+	pc, good := makeNoisyCloud(200)
+
+	out, err := PCStatisticalOutlierFilter(pc, 10, 1)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, out.Size(), test.ShouldBeLessThanOrEqualTo, good)
+	test.That(t, out.Size(), test.ShouldBeGreaterThan, good*95/100)
+
+	md := out.MetaData()
+	test.That(t, md.MaxZ, test.ShouldBeLessThan, 20)
+
+	_, err = PCStatisticalOutlierFilter(pc, 0, 1)
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func TestPCRadiusOutlierFilter(t *testing.T) {
+	pc, good := makeNoisyCloud(200)
+
+	out, err := PCRadiusOutlierFilter(pc, 3, 2)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, out.Size(), test.ShouldEqual, good)
+
+	md := out.MetaData()
+	test.That(t, md.MaxZ, test.ShouldBeLessThan, 20)
+
+	_, err = PCRadiusOutlierFilter(pc, 0, 2)
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func TestOutlierConfigs(t *testing.T) {
+	pc, good := makeNoisyCloud(50)
+
+	var sc *StatisticalOutlierConfig
+	out, err := sc.Filter(pc)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, out.Size(), test.ShouldEqual, pc.Size())
+
+	sc = &StatisticalOutlierConfig{MeanK: 10}
+	test.That(t, sc.Validate(), test.ShouldNotBeNil)
+	sc.StdDev = 1
+	test.That(t, sc.Validate(), test.ShouldBeNil)
+
+	var rc *RadiusOutlierConfig
+	out, err = rc.Filter(pc)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, out.Size(), test.ShouldEqual, pc.Size())
+
+	rc = &RadiusOutlierConfig{Radius: 3}
+	test.That(t, rc.Validate(), test.ShouldNotBeNil)
+	rc.MinNeighbors = 2
+	test.That(t, rc.Validate(), test.ShouldBeNil)
+
+	out, err = rc.Filter(pc)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, out.Size(), test.ShouldEqual, good)
+}
+
+func TestKNearest(t *testing.T) {
+	pc, _ := makeNoisyCloud(20)
+	g := newVoxelGridFromPointCloud(pc, neighborGridSize(pc, 5))
+
+	for _, i := range []int{0, 500, 9999, 10005, 10019} {
+		got := g.kNearest(g.points[i], 5, i)
+		test.That(t, len(got), test.ShouldEqual, 5)
+
+		// compare to brute force
+		worst := g.points[i].Distance(g.points[got[4]])
+		closer := 0
+		for j, p := range g.points {
+			if j != i && g.points[i].Distance(p) < worst {
+				closer++
+			}
+		}
+		test.That(t, closer, test.ShouldBeLessThanOrEqualTo, 4)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"image"
 	"math"
+	"sort"
 
 	"github.com/golang/geo/r3"
 
@@ -32,146 +33,508 @@ func init() {
 		})
 }
 
-func within(a, b pointcloud.PointCloud, within float64) bool {
-	amd := a.MetaData()
-	bmd := b.MetaData()
+// clusterNeighborOffsets are half of the 26 cells around a voxel, so each adjacent pair is only checked once.
+var clusterNeighborOffsets = func() []voxelKey {
+	offsets := []voxelKey{}
+	for dx := -1; dx <= 1; dx++ {
+		for dy := -1; dy <= 1; dy++ {
+			for dz := -1; dz <= 1; dz++ {
+				k := voxelKey{dx, dy, dz}
+				if k.X > 0 || (k.X == 0 && k.Y > 0) || (k.X == 0 && k.Y == 0 && k.Z > 0) {
+					offsets = append(offsets, k)
+				}
+			}
+		}
+	}
+	return offsets
+}()
+
+// cellsWithin returns true if any point in a is less than within from any point in b.
+// a and b must be adjacent cells of g.
+func cellsWithin(g *voxelGrid, a, b *voxelCell, within float64) bool {
+	aMin, aMax := g.cellBounds(a.key)
+	bMin, bMax := g.cellBounds(b.key)
+
+	// pick an axis the cells are separated on, points further apart than within on it can be skipped
+	axis := func(p r3.Vector) float64 { return p.Z }
+	dir := b.key.Z - a.key.Z
+	if b.key.X != a.key.X {
+		axis = func(p r3.Vector) float64 { return p.X }
+		dir = b.key.X - a.key.X
+	} else if b.key.Y != a.key.Y {
+		axis = func(p r3.Vector) float64 { return p.Y }
+		dir = b.key.Y - a.key.Y
+	}
 
-	d := math.Abs(amd.Center().Distance(bmd.Center()))
-	if d > (amd.MaxSideLength() + bmd.MaxSideLength()) {
+	as := []r3.Vector{}
+	for _, i := range a.points {
+		p := g.points[i]
+		if distanceToBox(p, bMin, bMax) < within {
+			as = append(as, p)
+		}
+	}
+	if len(as) == 0 {
 		return false
 	}
 
-	good := false
-	a.Iterate(0, 0, func(ap r3.Vector, _ pointcloud.Data) bool {
-		b.Iterate(0, 0, func(bp r3.Vector, _ pointcloud.Data) bool {
-			d := math.Abs(ap.Distance(bp))
-			if d < within {
-				good = true
-				return false
+	bs := []r3.Vector{}
+	for _, i := range b.points {
+		p := g.points[i]
+		if distanceToBox(p, aMin, aMax) < within {
+			bs = append(bs, p)
+		}
+	}
+	if len(bs) == 0 {
+		return false
+	}
+
+	// sort so points nearest the shared boundary come first
+	sign := float64(dir)
+	sort.Slice(as, func(i, j int) bool { return sign*axis(as[i]) > sign*axis(as[j]) })
+	sort.Slice(bs, func(i, j int) bool { return sign*axis(bs[i]) < sign*axis(bs[j]) })
+
+	within2 := within * within
+	for _, ap := range as {
+		for _, bp := range bs {
+			if sign*(axis(bp)-axis(ap)) >= within {
+				break
 			}
-			return true
-		})
-		return true
-	})
+			if ap.Sub(bp).Norm2() < within2 {
+				return true
+			}
+		}
+	}
 
-	return good
+	return false
 }
 
-func addAll(sink, source pointcloud.PointCloud) error {
-	var err error
-	source.Iterate(0, 0, func(p r3.Vector, d pointcloud.Data) bool {
-		err = sink.Set(p, d)
-		return err == nil
-	})
-	return err
+// clusterBounds is the axis aligned bounds of a group of points.
+type clusterBounds struct {
+	min, max r3.Vector
 }
 
-func merge(a, b *pointcloud.BasicOctree) (*pointcloud.BasicOctree, error) {
-	x := pointcloud.NewBasicPointCloud(a.Size() + b.Size())
-
-	err := addAll(x, a)
-	if err != nil {
-		return nil, err
+func newClusterBounds(g *voxelGrid, c *voxelCell) clusterBounds {
+	b := clusterBounds{min: g.points[c.points[0]], max: g.points[c.points[0]]}
+	for _, i := range c.points[1:] {
+		b = b.union(clusterBounds{min: g.points[i], max: g.points[i]})
 	}
+	return b
+}
 
-	err = addAll(x, b)
-	if err != nil {
-		return nil, err
+func (b clusterBounds) union(o clusterBounds) clusterBounds {
+	return clusterBounds{
+		min: r3.Vector{X: math.Min(b.min.X, o.min.X), Y: math.Min(b.min.Y, o.min.Y), Z: math.Min(b.min.Z, o.min.Z)},
+		max: r3.Vector{X: math.Max(b.max.X, o.max.X), Y: math.Max(b.max.Y, o.max.Y), Z: math.Max(b.max.Z, o.max.Z)},
 	}
+}
 
-	return pointcloud.ToBasicOctree(x, 0)
+func (b clusterBounds) maxSide() float64 {
+	d := b.max.Sub(b.min)
+	return math.Max(d.X, math.Max(d.Y, d.Z))
 }
 
+// near returns false if b and o are too far apart to be joined,
+// their centers are further apart than the sum of their max sides.
+func (b clusterBounds) near(o clusterBounds) bool {
+	bc := b.min.Add(b.max).Mul(.5)
+	oc := o.min.Add(o.max).Mul(.5)
+	return bc.Distance(oc) <= b.maxSide()+o.maxSide()
+}
+
+// Cluster groups points that are connected by gaps smaller than maxDistance.
+// Points are bucketed into cubes of side maxDistance, cubes with fewer than minPointsPerSegment points are dropped,
+// then touching cubes are merged with a union find.
+// Only clusters with more than minPointsPerCluster points are returned.
+//
+// Two groups are only joined if the distance between the centers of their bounds is at most the sum of their max sides,
+// the same check the original pairwise merge did, so results match it.
 func Cluster(pc pointcloud.PointCloud, maxDistance float64, minPointsPerSegment, minPointsPerCluster int) ([]pointcloud.PointCloud, error) {
+	if maxDistance <= 0 {
+		return nil, fmt.Errorf("maxDistance has to be positive, not %v", maxDistance)
+	}
 
-	buckets := map[string]pointcloud.PointCloud{}
+	g := newVoxelGridFromPointCloud(pc, maxDistance)
 
-	pc.Iterate(0, 0, func(p r3.Vector, d pointcloud.Data) bool {
-		b := fmt.Sprintf("%d-%d-%d",
-			int(math.Ceil(p.X/maxDistance)),
-			int(math.Ceil(p.Y/maxDistance)),
-			int(math.Ceil(p.Z/maxDistance)))
+	good := make([]bool, len(g.cells))
+	bounds := make([]clusterBounds, len(g.cells))
+	for idx, c := range g.cells {
+		good[idx] = minPointsPerSegment <= 0 || len(c.points) >= minPointsPerSegment
+		bounds[idx] = newClusterBounds(g, c)
+	}
 
-		pc, ok := buckets[b]
-		if !ok {
-			pc = pointcloud.NewBasicPointCloud(0)
-			buckets[b] = pc
+	// pairs of neighboring cells with points closer than maxDistance, these don't change as groups grow
+	touching := [][2]int{}
+	for idx, c := range g.cells {
+		if !good[idx] {
+			continue
 		}
-		err := pc.Set(p, d)
-		if err != nil {
-			panic(err)
+		for _, o := range clusterNeighborOffsets {
+			nidx, n := g.cell(c.key.add(o.X, o.Y, o.Z))
+			if n == nil || !good[nidx] {
+				continue
+			}
+			if cellsWithin(g, c, n, maxDistance) {
+				touching = append(touching, [2]int{idx, nidx})
+			}
 		}
-		return true
-	})
+	}
 
-	segments := []*pointcloud.BasicOctree{}
+	// joining groups grows their bounds, which can let other groups join, so go until nothing changes
+	uf := newUnionFind(len(g.cells))
+	for changed := true; changed; {
+		changed = false
+		for _, t := range touching {
+			ra, rb := uf.find(t[0]), uf.find(t[1])
+			if ra == rb || !bounds[ra].near(bounds[rb]) {
+				continue
+			}
+			uf.union(ra, rb)
+			bounds[uf.find(ra)] = bounds[ra].union(bounds[rb])
+			changed = true
+		}
+	}
 
-	for _, b := range buckets {
-		if minPointsPerSegment > 0 && b.Size() < minPointsPerSegment {
+	members := map[int][]int{}
+	order := []int{}
+	for idx, c := range g.cells {
+		if !good[idx] {
 			continue
 		}
+		root := uf.find(idx)
+		if _, ok := members[root]; !ok {
+			order = append(order, root)
+		}
+		members[root] = append(members[root], c.points...)
+	}
+
+	groups := [][]int{}
+	for _, root := range order {
+		groups = append(groups, members[root])
+	}
 
-		o, err := pointcloud.ToBasicOctree(b, 0)
+	return clusterGroupsToPointClouds(g, groups, minPointsPerCluster)
+}
+
+// clusterGroupsToPointClouds turns groups of point indexes into clouds, skipping groups with minPointsPerCluster or fewer points.
+func clusterGroupsToPointClouds(g *voxelGrid, groups [][]int, minPointsPerCluster int) ([]pointcloud.PointCloud, error) {
+	clusters := []pointcloud.PointCloud{}
+	for _, idxs := range groups {
+		if len(idxs) <= minPointsPerCluster {
+			continue
+		}
+		pc, err := g.subset(idxs)
 		if err != nil {
 			return nil, err
 		}
+		clusters = append(clusters, pc)
+	}
+	return clusters, nil
+}
 
-		segments = append(segments, o)
+// ClusterDBSCAN clusters with DBSCAN.
+// A point with at least minPoints points (including itself) within eps is a core point,
+// clusters are core points within eps of each other plus the points they reach.
+// Points not reachable from a core point are noise and dropped.
+// Only clusters with more than minPointsPerCluster points are returned.
+func ClusterDBSCAN(pc pointcloud.PointCloud, eps float64, minPoints, minPointsPerCluster int) ([]pointcloud.PointCloud, error) {
+	if eps <= 0 {
+		return nil, fmt.Errorf("eps has to be positive, not %v", eps)
 	}
 
-	for {
-		start := len(segments)
-		for x := 0; x < len(segments); x++ {
-			for y := x + 1; y < len(segments); y++ {
-				if within(segments[x], segments[y], maxDistance) {
-					n, err := merge(segments[x], segments[y])
-					if err != nil {
-						return nil, err
+	g := newVoxelGridFromPointCloud(pc, eps)
+
+	const (
+		unvisited = -2
+		noise     = -1
+	)
+
+	labels := make([]int, len(g.points))
+	for i := range labels {
+		labels[i] = unvisited
+	}
+
+	groups := [][]int{}
+
+	for i, p := range g.points {
+		if labels[i] != unvisited {
+			continue
+		}
+
+		ns := g.neighbors(p, eps)
+		if len(ns) < minPoints {
+			labels[i] = noise
+			continue
+		}
+
+		id := len(groups)
+		labels[i] = id
+		group := []int{i}
+
+		queue := ns
+		for len(queue) > 0 {
+			j := queue[0]
+			queue = queue[1:]
+
+			if labels[j] == noise {
+				labels[j] = id
+				group = append(group, j)
+				continue
+			}
+			if labels[j] != unvisited {
+				continue
+			}
+
+			labels[j] = id
+			group = append(group, j)
+
+			jns := g.neighbors(g.points[j], eps)
+			if len(jns) >= minPoints {
+				for _, k := range jns {
+					if labels[k] < 0 {
+						queue = append(queue, k)
 					}
-					segments[x] = n
-					segments = append(segments[0:y], segments[y+1:]...)
 				}
 			}
 		}
-		if len(segments) == start {
-			break
+
+		groups = append(groups, group)
+	}
+
+	return clusterGroupsToPointClouds(g, groups, minPointsPerCluster)
+}
+
+// RegionGrowingOptions configures ClusterRegionGrowing.
+type RegionGrowingOptions struct {
+	// MaxDistance is how close a neighbor has to be to join a cluster.
+	MaxDistance float64
+	// NormalRadius is the radius used to estimate surface normals, MaxDistance is used if <= 0.
+	NormalRadius float64
+	// MaxNormalAngleDegs is the biggest angle allowed between neighboring normals, <= 0 to ignore normals.
+	MaxNormalAngleDegs float64
+	// MaxCurvature, points curvier than this join a cluster but don't grow it further, defaults to .05.
+	MaxCurvature float64
+	// MaxColorDistance is the biggest EuclideanRGB allowed between neighbors, <= 0 to ignore color.
+	MaxColorDistance float64
+	// MinPointsPerCluster, only clusters with more points than this are returned.
+	MinPointsPerCluster int
+}
+
+func (o RegionGrowingOptions) normalRadius() float64 {
+	if o.NormalRadius <= 0 {
+		return o.MaxDistance
+	}
+	return o.NormalRadius
+}
+
+func (o RegionGrowingOptions) maxCurvature() float64 {
+	if o.MaxCurvature <= 0 {
+		return .05
+	}
+	return o.MaxCurvature
+}
+
+// ClusterRegionGrowing grows clusters from the flattest points outwards to neighbors within MaxDistance,
+// only adding neighbors with similar surface normals and colors.
+// This splits touching objects that Cluster would glue together.
+func ClusterRegionGrowing(pc pointcloud.PointCloud, opts RegionGrowingOptions) ([]pointcloud.PointCloud, error) {
+	if opts.MaxDistance <= 0 {
+		return nil, fmt.Errorf("MaxDistance has to be positive, not %v", opts.MaxDistance)
+	}
+
+	maxDistance := opts.MaxDistance
+	normalRadius := opts.normalRadius()
+	maxNormalAngleDegs := opts.MaxNormalAngleDegs
+	maxColorDistance := opts.MaxColorDistance
+
+	g := newVoxelGridFromPointCloud(pc, maxDistance)
+
+	normals := make([]r3.Vector, len(g.points))
+	hasNormal := make([]bool, len(g.points))
+	curvature := make([]float64, len(g.points))
+
+	if maxNormalAngleDegs > 0 {
+		ng := g
+		if normalRadius != maxDistance {
+			ng = newVoxelGridFromPointCloud(pc, normalRadius)
+		}
+		for i, p := range g.points {
+			neighbors := []r3.Vector{}
+			ng.forEachNeighbor(p, normalRadius, func(j int) bool {
+				neighbors = append(neighbors, ng.points[j])
+				return true
+			})
+			normals[i], curvature[i], hasNormal[i] = estimateNormal(neighbors)
+			if !hasNormal[i] {
+				curvature[i] = math.Inf(1)
+			}
 		}
 	}
 
-	clusters := []pointcloud.PointCloud{}
-	for _, o := range segments {
-		if o.Size() > minPointsPerCluster {
-			clusters = append(clusters, o)
+	minCos := math.Cos(maxNormalAngleDegs * math.Pi / 180)
+
+	similar := func(a, b int) bool {
+		if maxNormalAngleDegs > 0 {
+			if !hasNormal[a] || !hasNormal[b] {
+				return false
+			}
+			// normals from PCA don't have a consistent direction
+			if math.Abs(normals[a].Dot(normals[b])) < minCos {
+				return false
+			}
 		}
+		// color is only compared when both points have one
+		if maxColorDistance > 0 && hasColor(g.data[a]) && hasColor(g.data[b]) {
+			if EuclideanRGB(g.data[a].Color(), g.data[b].Color()) > maxColorDistance {
+				return false
+			}
+		}
+		return true
 	}
 
-	return clusters, nil
+	maxCurvature := opts.maxCurvature()
+	canGrow := func(i int) bool {
+		return maxNormalAngleDegs <= 0 || curvature[i] <= maxCurvature
+	}
+
+	seeds := make([]int, len(g.points))
+	for i := range seeds {
+		seeds[i] = i
+	}
+	sort.SliceStable(seeds, func(i, j int) bool { return curvature[seeds[i]] < curvature[seeds[j]] })
+
+	assigned := make([]bool, len(g.points))
+	groups := [][]int{}
+
+	for _, seed := range seeds {
+		if assigned[seed] {
+			continue
+		}
+
+		assigned[seed] = true
+		group := []int{seed}
+
+		for next := 0; next < len(group); next++ {
+			cur := group[next]
+			if next > 0 && !canGrow(cur) {
+				continue
+			}
+			g.forEachNeighbor(g.points[cur], maxDistance, func(j int) bool {
+				if !assigned[j] && similar(cur, j) {
+					assigned[j] = true
+					group = append(group, j)
+				}
+				return true
+			})
+		}
+
+		groups = append(groups, group)
+	}
+
+	return clusterGroupsToPointClouds(g, groups, opts.MinPointsPerCluster)
 }
 
+func hasColor(d pointcloud.Data) bool {
+	return d != nil && d.HasColor()
+}
+
+const (
+	ClusterAlgorithmVoxel         = "voxel"
+	ClusterAlgorithmDBSCAN        = "dbscan"
+	ClusterAlgorithmRegionGrowing = "region-growing"
+)
+
 type ClusterConfig struct {
 	Camera              string
+	Algorithm           string  `json:"algorithm"` // voxel (default), dbscan, or region-growing
 	MaxDistance         float64 `json:"max-distance"`
-	MinPointsPerSegment int     `json:"min-points-per-segment"`
+	MinPointsPerSegment int     `json:"min-points-per-segment"` // for dbscan, neighbors needed to be a core point
 	MinPointsPerCluster int     `json:"min-points-per-cluster"`
+
+	// region-growing only
+	NormalRadius       float64 `json:"normal-radius"`
+	MaxNormalAngleDegs float64 `json:"max-normal-angle-degs"`
+	MaxCurvature       float64 `json:"max-curvature"`
+	MaxColorDistance   float64 `json:"max-color-distance"`
+
+	RemovePlane *PlaneRemovalConfig `json:"remove-plane"` // strip the table before clustering
+}
+
+func (cc *ClusterConfig) algorithm() string {
+	if cc.Algorithm == "" {
+		return ClusterAlgorithmVoxel
+	}
+	return cc.Algorithm
 }
 
 func (cc *ClusterConfig) Validate(p string) ([]string, []string, error) {
 	if cc.Camera == "" {
-		return nil, nil, fmt.Errorf("need to specifiy camera")
+		return nil, nil, fmt.Errorf("need to specify camera")
 	}
 
-	if cc.MaxDistance <= 0 {
-		return nil, nil, fmt.Errorf("need to spefict max-distance")
+	err := cc.validateAlgorithm()
+	if err != nil {
+		return nil, nil, err
 	}
 
-	if cc.MinPointsPerSegment <= 0 {
-		return nil, nil, fmt.Errorf("need to spefict min-points-per-segment")
+	return []string{cc.Camera}, nil, nil
+}
+
+func (cc *ClusterConfig) validateAlgorithm() error {
+	switch cc.algorithm() {
+	case ClusterAlgorithmVoxel, ClusterAlgorithmDBSCAN:
+		if cc.MinPointsPerSegment <= 0 {
+			return fmt.Errorf("need to specify min-points-per-segment")
+		}
+	case ClusterAlgorithmRegionGrowing:
+		if cc.MaxNormalAngleDegs < 0 || cc.MaxNormalAngleDegs > 180 {
+			return fmt.Errorf("max-normal-angle-degs has to be between 0 and 180, not %v", cc.MaxNormalAngleDegs)
+		}
+	default:
+		return fmt.Errorf("unknown algorithm [%s]", cc.Algorithm)
 	}
+
+	if cc.MaxDistance <= 0 {
+		return fmt.Errorf("need to specify max-distance")
+	}
+
 	if cc.MinPointsPerCluster <= 0 {
-		return nil, nil, fmt.Errorf("need to spefict min-points-per-cluster")
+		return fmt.Errorf("need to specify min-points-per-cluster")
 	}
 
-	return []string{cc.Camera}, nil, nil
+	if cc.RemovePlane != nil {
+		return cc.RemovePlane.Validate()
+	}
+
+	return nil
+}
+
+// Cluster removes the table if configured, then runs the configured algorithm on pc.
+func (cc *ClusterConfig) Cluster(pc pointcloud.PointCloud) ([]pointcloud.PointCloud, error) {
+	err := cc.validateAlgorithm()
+	if err != nil {
+		return nil, err
+	}
+
+	pc, err = cc.RemovePlane.RemovePlane(pc)
+	if err != nil {
+		return nil, err
+	}
+
+	switch cc.algorithm() {
+	case ClusterAlgorithmDBSCAN:
+		return ClusterDBSCAN(pc, cc.MaxDistance, cc.MinPointsPerSegment, cc.MinPointsPerCluster)
+	case ClusterAlgorithmRegionGrowing:
+		return ClusterRegionGrowing(pc, RegionGrowingOptions{
+			MaxDistance:         cc.MaxDistance,
+			NormalRadius:        cc.NormalRadius,
+			MaxNormalAngleDegs:  cc.MaxNormalAngleDegs,
+			MaxCurvature:        cc.MaxCurvature,
+			MaxColorDistance:    cc.MaxColorDistance,
+			MinPointsPerCluster: cc.MinPointsPerCluster,
+		})
+	default:
+		return Cluster(pc, cc.MaxDistance, cc.MinPointsPerSegment, cc.MinPointsPerCluster)
+	}
 }
 
 type ClusterService struct {
@@ -237,7 +600,7 @@ func (cs *ClusterService) GetObjectPointClouds(ctx context.Context, cameraName s
 		return nil, err
 	}
 
-	clusters, err := Cluster(pc, cs.conf.MaxDistance, cs.conf.MinPointsPerSegment, cs.conf.MinPointsPerCluster)
+	clusters, err := cs.conf.Cluster(pc)
 	if err != nil {
 		return nil, err
 	}
@@ -1,8 +1,12 @@
 package touch
 
 import (
+	"image/color"
+	"math"
 	"testing"
 
+	"github.com/golang/geo/r3"
+
 	"go.viam.com/rdk/pointcloud"
 	"go.viam.com/test"
 )
@@ -14,6 +18,106 @@ func TestCluster1(t *testing.T) {
 	clusters, err := Cluster(in, 30, 20, 100)
 	test.That(t, err, test.ShouldBeNil)
 	test.That(t, len(clusters), test.ShouldEqual, 1)
+	test.That(t, clusters[0].Size(), test.ShouldEqual, 11229)
+
+	md := clusters[0].MetaData()
+	test.That(t, md.Center().X, test.ShouldAlmostEqual, 332.55, .01)
+	test.That(t, md.Center().Y, test.ShouldAlmostEqual, 358.5, .01)
+	test.That(t, md.Center().Z, test.ShouldAlmostEqual, 81.6, .01)
+}
+
+func TestCluster2(t *testing.T) {
+	in, err := pointcloud.NewFromFile("data/glass1.pcd", "")
+	test.That(t, err, test.ShouldBeNil)
+
+	clusters, err := Cluster(in, 10, 5, 50)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(clusters), test.ShouldEqual, 7)
+
+	total := 0
+	for _, c := range clusters {
+		total += c.Size()
+	}
+	test.That(t, total, test.ShouldEqual, 11238)
+}
+
+func TestClusterSmallSegments(t *testing.T) {
+	in, err := pointcloud.NewFromFile("data/glass1.pcd", "")
+	test.That(t, err, test.ShouldBeNil)
+
+	// same as the original pairwise merge
+	clusters, err := Cluster(in, 3, 0, 0)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(clusters), test.ShouldEqual, 33)
+
+	clusters, err = Cluster(in, 7, 2, 30)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(clusters), test.ShouldEqual, 10)
+
+	// single points have no size, so like the original they're only joined to groups big enough to reach them
+	pc := pointcloud.NewBasicPointCloud(0)
+	test.That(t, pc.Set(r3.Vector{X: 9.5}, pointcloud.NewBasicData()), test.ShouldBeNil)
+	test.That(t, pc.Set(r3.Vector{X: 10.5}, pointcloud.NewBasicData()), test.ShouldBeNil)
+	test.That(t, pc.Set(r3.Vector{X: 30}, pointcloud.NewBasicData()), test.ShouldBeNil)
+	clusters, err = Cluster(pc, 10, 0, 0)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(clusters), test.ShouldEqual, 3)
+
+	test.That(t, pc.Set(r3.Vector{X: 7.5}, pointcloud.NewBasicData()), test.ShouldBeNil)
+	clusters, err = Cluster(pc, 10, 0, 0)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(clusters), test.ShouldEqual, 2)
+	test.That(t, clusters[0].Size(), test.ShouldEqual, 3)
+}
+
+func TestClusterSeparated(t *testing.T) {
+	pc := makeClusterTestCloud(20000)
+
+	clusters, err := Cluster(pc, 10, 5, 100)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(clusters), test.ShouldEqual, 5)
+
+	total := 0
+	for _, c := range clusters {
+		total += c.Size()
+	}
+	test.That(t, total, test.ShouldBeGreaterThan, pc.Size()*9/10)
+
+	_, err = Cluster(pc, 0, 5, 100)
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+// makeClusterTestCloud makes a table with 4 balls floating above it, roughly n points total.
+func makeClusterTestCloud(n int) pointcloud.PointCloud {
+	pc := pointcloud.NewBasicPointCloud(n)
+
+	tableSide := int(math.Sqrt(float64(n) / 2))
+	spacing := 300 / float64(tableSide)
+	for x := 0; x < tableSide; x++ {
+		for y := 0; y < tableSide; y++ {
+			pc.Set(r3.Vector{X: float64(x) * spacing, Y: float64(y) * spacing}, pointcloud.NewBasicData())
+		}
+	}
+
+	perBall := (n - pc.Size()) / 4
+	rings := int(math.Sqrt(float64(perBall)))
+	for b := 0; b < 4; b++ {
+		center := r3.Vector{X: 75 + float64(b%2)*150, Y: 75 + float64(b/2)*150, Z: 100}
+		for i := 0; i < rings; i++ {
+			lat := math.Pi * (float64(i) + .5) / float64(rings)
+			for j := 0; j < rings; j++ {
+				lon := 2 * math.Pi * float64(j) / float64(rings)
+				p := r3.Vector{
+					X: math.Sin(lat) * math.Cos(lon),
+					Y: math.Sin(lat) * math.Sin(lon),
+					Z: math.Cos(lat),
+				}
+				pc.Set(center.Add(p.Mul(40)), pointcloud.NewBasicData())
+			}
+		}
+	}
+
+	return pc
 }
 
 func BenchmarkCluster1(t *testing.B) {
@@ -27,3 +131,116 @@ func BenchmarkCluster1(t *testing.B) {
 		test.That(t, len(clusters), test.ShouldEqual, 1)
 	}
 }
+
+func BenchmarkClusterLarge(t *testing.B) {
+	in := makeClusterTestCloud(300000)
+	test.That(t, in.Size(), test.ShouldBeGreaterThan, 290000)
+
+	t.ResetTimer()
+	for range t.N {
+		clusters, err := Cluster(in, 10, 5, 100)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, len(clusters), test.ShouldEqual, 5)
+	}
+}
+
+func TestClusterDBSCAN(t *testing.T) {
+	pc := makeClusterTestCloud(20000)
+
+	clusters, err := ClusterDBSCAN(pc, 10, 5, 100)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(clusters), test.ShouldEqual, 5)
+
+	// a lone point far away is noise
+	pc.Set(r3.Vector{X: 5000, Y: 5000, Z: 5000}, pointcloud.NewBasicData())
+	clusters, err = ClusterDBSCAN(pc, 10, 5, 0)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(clusters), test.ShouldEqual, 5)
+
+	in, err := pointcloud.NewFromFile("data/glass1.pcd", "")
+	test.That(t, err, test.ShouldBeNil)
+
+	clusters, err = ClusterDBSCAN(in, 30, 20, 100)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(clusters), test.ShouldEqual, 1)
+}
+
+// makeTouchingTestCloud makes a white floor with a red wall standing on it.
+func makeTouchingTestCloud() pointcloud.PointCloud {
+	pc := pointcloud.NewBasicEmpty()
+	white := pointcloud.NewColoredData(color.NRGBA{255, 255, 255, 255})
+	red := pointcloud.NewColoredData(color.NRGBA{255, 0, 0, 255})
+
+	for x := 0; x < 100; x++ {
+		for y := 0; y < 100; y++ {
+			pc.Set(r3.Vector{X: float64(x * 2), Y: float64(y * 2)}, white)
+		}
+	}
+	for y := 0; y < 100; y++ {
+		for z := 1; z < 50; z++ {
+			pc.Set(r3.Vector{X: 100, Y: float64(y * 2), Z: float64(z * 2)}, red)
+		}
+	}
+	return pc
+}
+
+func TestClusterRegionGrowing(t *testing.T) {
+	pc := makeTouchingTestCloud()
+
+	clusters, err := Cluster(pc, 5, 1, 100)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(clusters), test.ShouldEqual, 1)
+
+	clusters, err = ClusterRegionGrowing(pc, RegionGrowingOptions{MaxDistance: 5, MinPointsPerCluster: 100})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(clusters), test.ShouldEqual, 1)
+
+	clusters, err = ClusterRegionGrowing(pc, RegionGrowingOptions{MaxDistance: 5, MaxColorDistance: 50, MinPointsPerCluster: 100})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(clusters), test.ShouldEqual, 2)
+
+	clusters, err = ClusterRegionGrowing(pc, RegionGrowingOptions{MaxDistance: 5, MaxNormalAngleDegs: 20, MinPointsPerCluster: 1000})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(clusters), test.ShouldBeGreaterThanOrEqualTo, 2)
+	for _, c := range clusters {
+		md := c.MetaData()
+		flat := md.MaxZ-md.MinZ < 1
+		upright := md.MaxX-md.MinX < 1
+		test.That(t, flat || upright, test.ShouldBeTrue)
+	}
+
+	// points without data can't be compared by color, so they aren't split by it
+	plain := pointcloud.NewBasicPointCloud(0)
+	pc.Iterate(0, 0, func(p r3.Vector, d pointcloud.Data) bool {
+		test.That(t, plain.Set(p, nil), test.ShouldBeNil)
+		return true
+	})
+	clusters, err = ClusterRegionGrowing(plain, RegionGrowingOptions{MaxDistance: 5, MaxColorDistance: 50, MinPointsPerCluster: 100})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(clusters), test.ShouldEqual, 1)
+}
+
+func TestClusterConfig(t *testing.T) {
+	cc := &ClusterConfig{Camera: "c", MaxDistance: 5, MinPointsPerSegment: 1, MinPointsPerCluster: 100}
+	_, _, err := cc.Validate("")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, cc.algorithm(), test.ShouldEqual, ClusterAlgorithmVoxel)
+
+	cc.Algorithm = "foo"
+	_, _, err = cc.Validate("")
+	test.That(t, err, test.ShouldNotBeNil)
+
+	cc.Algorithm = ClusterAlgorithmRegionGrowing
+	cc.MinPointsPerSegment = 0
+	cc.MaxColorDistance = 50
+	_, _, err = cc.Validate("")
+	test.That(t, err, test.ShouldBeNil)
+
+	clusters, err := cc.Cluster(makeTouchingTestCloud())
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(clusters), test.ShouldEqual, 2)
+
+	cc.Algorithm = ClusterAlgorithmDBSCAN
+	_, _, err = cc.Validate("")
+	test.That(t, err, test.ShouldNotBeNil)
+}
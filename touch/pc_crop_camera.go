@@ -2,7 +2,10 @@ package touch
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"math"
+	"slices"
 	"sync"
 	"time"
 
@@ -11,10 +14,12 @@ import (
 	"go.viam.com/rdk/components/camera"
 	"go.viam.com/rdk/logging"
 	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/referenceframe"
 	"go.viam.com/rdk/resource"
 	"go.viam.com/rdk/rimage"
-	"go.viam.com/rdk/robot"
+	"go.viam.com/rdk/robot/framesystem"
 	"go.viam.com/rdk/spatialmath"
+	"go.viam.com/rdk/utils"
 
 	"github.com/erh/vmodutils"
 )
@@ -31,19 +36,93 @@ func init() {
 }
 
 type CropCameraConfig struct {
-	Src      string
-	SrcFrame string `json:"src_frame"`
-	Min      r3.Vector
-	Max      r3.Vector
+	Src      string    `json:"src"`
+	SrcFrame string    `json:"src_frame,omitempty"`
+	Min      r3.Vector `json:"min"`
+	Max      r3.Vector `json:"max"`
 
-	GoodColors []ColorFilter `json:"good_colors"`
+	GoodColors []ColorFilter `json:"good_colors,omitempty"`
+
+	// points are kept if they're in any include region and not in any exclude region
+	// if there are include regions min and max are optional
+	Include []CropRegion `json:"include,omitempty"`
+	Exclude []CropRegion `json:"exclude,omitempty"`
+
+	// filters run after cropping, in this order
+	StatisticalOutlier *StatisticalOutlierConfig `json:"statistical_outlier,omitempty"`
+	RadiusOutlier      *RadiusOutlierConfig      `json:"radius_outlier,omitempty"`
+	RemovePlane        *PlaneRemovalConfig       `json:"remove_plane,omitempty"`
+
+	// how long a caller waits for a point cloud another caller is already getting, defaults to 60
+	WaitTimeoutSeconds float64 `json:"wait_timeout_seconds,omitempty"`
+}
+
+func (ccc *CropCameraConfig) waitTimeout() time.Duration {
+	if ccc.WaitTimeoutSeconds <= 0 {
+		return time.Minute
+	}
+	return time.Duration(ccc.WaitTimeoutSeconds * float64(time.Second))
 }
 
 func (ccc *CropCameraConfig) Validate(path string) ([]string, []string, error) {
 	if ccc.Src == "" {
 		return nil, nil, fmt.Errorf("need a src camera")
 	}
-	return []string{ccc.Src}, nil, nil
+	for _, r := range slices.Concat(ccc.Include, ccc.Exclude) {
+		_, err := r.ParseGeometries()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if ccc.StatisticalOutlier != nil {
+		err := ccc.StatisticalOutlier.Validate()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if ccc.RadiusOutlier != nil {
+		err := ccc.RadiusOutlier.Validate()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if ccc.RemovePlane != nil {
+		err := ccc.RemovePlane.Validate()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if ccc.WaitTimeoutSeconds < 0 {
+		return nil, nil, fmt.Errorf("wait_timeout_seconds can't be negative")
+	}
+	return []string{ccc.Src, framesystem.PublicServiceName.String()}, nil, nil
+}
+
+func (ccc *CropCameraConfig) srcFrame() string {
+	if ccc.SrcFrame != "" {
+		return ccc.SrcFrame
+	}
+	return ccc.Src
+}
+
+// frameNames is every frame the crop needs to know about.
+func (ccc *CropCameraConfig) frameNames() []string {
+	names := []string{ccc.srcFrame()}
+	for _, r := range slices.Concat(ccc.Include, ccc.Exclude) {
+		if r.frame() != referenceframe.World && !slices.Contains(names, r.frame()) {
+			names = append(names, r.frame())
+		}
+	}
+	return names
+}
+
+// cropBox returns the min and max of the box to crop to, if there are include regions and no box everything is in the box.
+func (ccc *CropCameraConfig) cropBox() (r3.Vector, r3.Vector) {
+	if len(ccc.Include) > 0 && ccc.Min == (r3.Vector{}) && ccc.Max == (r3.Vector{}) {
+		big := math.MaxFloat64
+		return r3.Vector{X: -big, Y: -big, Z: -big}, r3.Vector{X: big, Y: big, Z: big}
+	}
+	return ccc.Min, ccc.Max
 }
 
 func newCropCamera(ctx context.Context, deps resource.Dependencies, config resource.Config, logger logging.Logger) (camera.Camera, error) {
@@ -57,35 +136,36 @@ func newCropCamera(ctx context.Context, deps resource.Dependencies, config resou
 		cfg:    newConf,
 		logger: logger,
 	}
+	cc.flight.Timeout = newConf.waitTimeout()
 
 	cc.src, err = camera.FromProvider(deps, newConf.Src)
 	if err != nil {
 		return nil, err
 	}
 
-	cc.client, err = vmodutils.ConnectToMachineFromEnv(ctx, logger)
+	fs, err := framesystem.FromDependencies(deps)
 	if err != nil {
 		return nil, err
 	}
+	cc.transformer = NewLocalFrameTransformer(fs)
 
 	return cc, nil
 }
 
 type cropCamera struct {
 	resource.AlwaysRebuild
+	resource.TriviallyCloseable
 
 	name   resource.Name
-	cfg    *CropCameraConfig
 	logger logging.Logger
 
-	src    camera.Camera
-	client robot.Robot
+	cfgLock sync.Mutex
+	cfg     *CropCameraConfig // replaced, not modified, by DoCommand
+
+	src         camera.Camera
+	transformer *LocalFrameTransformer
 
-	lock               sync.Mutex
-	active             bool
-	lastPointCloud     pointcloud.PointCloud
-	lastPointCloudTime time.Time
-	lastPointCloudErr  error
+	flight vmodutils.SingleFlight[pointcloud.PointCloud] // concurrent callers share one point cloud
 }
 
 func (cc *cropCamera) Name() resource.Name {
@@ -125,59 +205,123 @@ func (cc *cropCamera) Images(ctx context.Context, filterSourceNames []string, ex
 	return []camera.NamedImage{ni}, resource.ResponseMetadata{time.Now()}, nil
 }
 
-func (cc *cropCamera) DoCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
-	return nil, nil
+func (cc *cropCamera) config() *CropCameraConfig {
+	cc.cfgLock.Lock()
+	defer cc.cfgLock.Unlock()
+	return cc.cfg
 }
 
-func (cc *cropCamera) NextPointCloud(ctx context.Context, extra map[string]interface{}) (pointcloud.PointCloud, error) {
+// cropCameraUpdate is what can be changed with the "set" DoCommand, nil fields are left alone.
+type cropCameraUpdate struct {
+	Min        *r3.Vector     `json:"min"`
+	Max        *r3.Vector     `json:"max"`
+	GoodColors *[]ColorFilter `json:"good_colors"`
+	SrcFrame   *string        `json:"src_frame"`
+}
 
-	start := time.Now()
-	cc.lock.Lock()
-	if cc.active {
-		cc.lock.Unlock()
-		return cc.waitForPointCloudAfter(ctx, start)
+func (u *cropCameraUpdate) apply(old *CropCameraConfig) (*CropCameraConfig, error) {
+	n := *old
+	if u.Min != nil {
+		n.Min = *u.Min
+	}
+	if u.Max != nil {
+		n.Max = *u.Max
+	}
+	if u.GoodColors != nil {
+		n.GoodColors = *u.GoodColors
+	}
+	if u.SrcFrame != nil {
+		n.SrcFrame = *u.SrcFrame
 	}
 
-	cc.active = true
-	cc.lock.Unlock()
-
-	pc, err := cc.doNextPointCloud(ctx, extra)
-
-	cc.lock.Lock()
-	cc.active = false
-	cc.lastPointCloud = pc
-	cc.lastPointCloudErr = err
-	cc.lastPointCloudTime = time.Now()
-	cc.lock.Unlock()
+	_, _, err := n.Validate("")
+	if err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
 
-	return pc, err
+// saveAttributes is the config as attributes for the "save" DoCommand.
+// Saving only replaces the attributes it has, so fields "set" can change are always there, even when empty.
+func (c *CropCameraConfig) saveAttributes() (utils.AttributeMap, error) {
+	attrs, err := vmodutils.ToAttributeMap(c)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := attrs["good_colors"]; !ok {
+		attrs["good_colors"] = []interface{}{}
+	}
+	if _, ok := attrs["src_frame"]; !ok {
+		attrs["src_frame"] = ""
+	}
+	return attrs, nil
 }
 
-func (cc *cropCamera) waitForPointCloudAfter(ctx context.Context, when time.Time) (pointcloud.PointCloud, error) {
-	for {
-		if ctx.Err() != nil {
-			return nil, ctx.Err()
+// DoCommand
+//
+//	{"get" : true} - returns the current min, max, good_colors, and src_frame
+//	{"set" : { "min" : ..., "max" : ..., "good_colors" : [...], "src_frame" : "..." }} - any subset, applied right away
+//	{"save" : true} - writes the current config back to the cloud, can be combined with "set"
+func (cc *cropCamera) DoCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	handled := false
+
+	if set, ok := cmd["set"]; ok {
+		jsonData, err := json.Marshal(set)
+		if err != nil {
+			return nil, err
+		}
+		u := &cropCameraUpdate{}
+		err = json.Unmarshal(jsonData, u)
+		if err != nil {
+			return nil, fmt.Errorf("bad set: %w", err)
 		}
 
-		if time.Since(when) > time.Minute {
-			return nil, fmt.Errorf("waitForPointCloudAfter timed out after %v", time.Since(when))
+		cc.cfgLock.Lock()
+		n, err := u.apply(cc.cfg)
+		if err == nil {
+			cc.cfg = n
 		}
+		cc.cfgLock.Unlock()
+		if err != nil {
+			return nil, err
+		}
+		handled = true
+	}
 
-		cc.lock.Lock()
-		if cc.lastPointCloudTime.After(when) {
-			pc := cc.lastPointCloud
-			err := cc.lastPointCloudErr
-			cc.lock.Unlock()
-			return pc, err
+	if cmd["save"] == true {
+		attrs, err := cc.config().saveAttributes()
+		if err != nil {
+			return nil, err
+		}
+		err = vmodutils.UpdateComponentCloudAttributesFromModuleEnv(ctx, cc.name, attrs, cc.logger)
+		if err != nil {
+			return nil, err
 		}
-		cc.lock.Unlock()
+		handled = true
+	}
 
-		time.Sleep(time.Millisecond * 50)
+	if cmd["get"] == true || handled {
+		cfg := cc.config()
+		return map[string]interface{}{
+			"min":         cfg.Min,
+			"max":         cfg.Max,
+			"good_colors": cfg.GoodColors,
+			"src_frame":   cfg.SrcFrame,
+		}, nil
 	}
+
+	return nil, fmt.Errorf("unknown command %v", cmd)
+}
+
+func (cc *cropCamera) NextPointCloud(ctx context.Context, extra map[string]interface{}) (pointcloud.PointCloud, error) {
+	return cc.flight.Do(ctx, func(ctx context.Context) (pointcloud.PointCloud, error) {
+		return cc.doNextPointCloud(ctx, extra)
+	})
 }
 
 func (cc *cropCamera) doNextPointCloud(ctx context.Context, extra map[string]interface{}) (pointcloud.PointCloud, error) {
 	start := time.Now()
+	cfg := cc.config()
 
 	pc, err := cc.src.NextPointCloud(ctx, extra)
 	if err != nil {
@@ -186,23 +330,52 @@ func (cc *cropCamera) doNextPointCloud(ctx context.Context, extra map[string]int
 
 	timeA := time.Since(start)
 
-	srcFrame := cc.cfg.Src
-	if cc.cfg.SrcFrame != "" {
-		srcFrame = cc.cfg.SrcFrame
+	err = cc.transformer.Update(ctx, cfg.frameNames())
+	if err != nil {
+		return nil, err
 	}
 
-	pc, err = cc.client.TransformPointCloud(ctx, pc, srcFrame, "world")
+	pc, err = cc.transformer.TransformPointCloud(pc, cfg.srcFrame(), referenceframe.World)
 	if err != nil {
 		return nil, err
 	}
 
 	timeB := time.Since(start)
 
-	pc = PCCropWithColor(pc, cc.cfg.Min, cc.cfg.Max, cc.cfg.GoodColors)
+	min, max := cfg.cropBox()
+	pc = PCCropWithColor(pc, min, max, cfg.GoodColors)
+
+	if len(cfg.Include) > 0 || len(cfg.Exclude) > 0 {
+		include, err := cropRegionsToWorld(ctx, cc.transformer, cfg.Include)
+		if err != nil {
+			return nil, err
+		}
+		exclude, err := cropRegionsToWorld(ctx, cc.transformer, cfg.Exclude)
+		if err != nil {
+			return nil, err
+		}
+		pc = PCCropWithGeometries(pc, include, exclude)
+	}
 	timeC := time.Since(start)
 
-	if timeC > (time.Millisecond * 250) {
-		cc.logger.Infof("cropCamera::NextPointCloud timeA: %v timeB: %v timeC: %v", timeA, timeB, timeC)
+	pc, err = cfg.StatisticalOutlier.Filter(pc)
+	if err != nil {
+		return nil, err
+	}
+
+	pc, err = cfg.RadiusOutlier.Filter(pc)
+	if err != nil {
+		return nil, err
+	}
+
+	pc, err = cfg.RemovePlane.RemovePlane(pc)
+	if err != nil {
+		return nil, err
+	}
+	timeD := time.Since(start)
+
+	if timeD > (time.Millisecond * 250) {
+		cc.logger.Infof("cropCamera::NextPointCloud timeA: %v timeB: %v timeC: %v timeD: %v", timeA, timeB, timeC, timeD)
 	}
 
 	return pc, nil
@@ -215,10 +388,6 @@ func (cc *cropCamera) Properties(ctx context.Context) (camera.Properties, error)
 	}, nil
 }
 
-func (cc *cropCamera) Close(ctx context.Context) error {
-	return cc.client.Close(ctx)
-}
-
 func (cc *cropCamera) Geometries(ctx context.Context, _ map[string]interface{}) ([]spatialmath.Geometry, error) {
 	return nil, nil
 }
@@ -0,0 +1,59 @@
+package touch
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/test"
+)
+
+func TestCropCameraDoCommand(t *testing.T) {
+	ctx := context.Background()
+
+	cc := &cropCamera{cfg: &CropCameraConfig{Src: "a", Min: r3.Vector{X: 1}, Max: r3.Vector{X: 2}}}
+	orig := cc.cfg
+
+	res, err := cc.DoCommand(ctx, map[string]interface{}{"get": true})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, res["min"], test.ShouldResemble, r3.Vector{X: 1})
+	test.That(t, res["src_frame"], test.ShouldEqual, "")
+
+	res, err = cc.DoCommand(ctx, map[string]interface{}{
+		"set": map[string]interface{}{
+			"max":         map[string]interface{}{"X": 5, "Y": 6, "Z": 7},
+			"src_frame":   "foo",
+			"good_colors": []interface{}{map[string]interface{}{"Color": map[string]interface{}{"R": 255}, "Distance": 10}},
+		},
+	})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, res["max"], test.ShouldResemble, r3.Vector{X: 5, Y: 6, Z: 7})
+	test.That(t, res["min"], test.ShouldResemble, r3.Vector{X: 1})
+
+	cfg := cc.config()
+	test.That(t, cfg.SrcFrame, test.ShouldEqual, "foo")
+	test.That(t, len(cfg.GoodColors), test.ShouldEqual, 1)
+	test.That(t, cfg.GoodColors[0].Color.R, test.ShouldEqual, 255)
+	test.That(t, cfg.GoodColors[0].Distance, test.ShouldEqual, 10.0)
+
+	// the old config is never modified
+	test.That(t, orig.Max, test.ShouldResemble, r3.Vector{X: 2})
+	test.That(t, orig.SrcFrame, test.ShouldEqual, "")
+
+	_, err = cc.DoCommand(ctx, map[string]interface{}{"set": map[string]interface{}{"min": "bad"}})
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, cc.config(), test.ShouldEqual, cfg)
+
+	_, err = cc.DoCommand(ctx, map[string]interface{}{"foo": true})
+	test.That(t, err, test.ShouldNotBeNil)
+
+	// clearing fields has to be saved too
+	_, err = cc.DoCommand(ctx, map[string]interface{}{"set": map[string]interface{}{"src_frame": "", "good_colors": []interface{}{}}})
+	test.That(t, err, test.ShouldBeNil)
+	attrs, err := cc.config().saveAttributes()
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, attrs["src_frame"], test.ShouldEqual, "")
+	test.That(t, attrs["good_colors"], test.ShouldResemble, []interface{}{})
+	test.That(t, attrs["src"], test.ShouldEqual, "a")
+}
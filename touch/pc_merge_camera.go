@@ -3,13 +3,17 @@ package touch
 import (
 	"context"
 	"fmt"
+	"slices"
+	"sync"
 	"time"
 
 	"go.viam.com/rdk/components/camera"
 	"go.viam.com/rdk/logging"
 	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/referenceframe"
 	"go.viam.com/rdk/resource"
 	"go.viam.com/rdk/rimage"
+	"go.viam.com/rdk/robot/framesystem"
 	"go.viam.com/rdk/spatialmath"
 
 	"github.com/erh/vmodutils"
@@ -26,16 +30,98 @@ func init() {
 		})
 }
 
+// MergeSource is a camera whose points are in frame, they get moved to the merge's target frame.
+type MergeSource struct {
+	Camera string  `json:"camera"`
+	Frame  string  `json:"frame"`  // defaults to the target frame, i.e. points are used as is
+	Weight float64 `json:"weight"` // how much this camera counts when fusing, defaults to 1
+}
+
+func (s MergeSource) weight() float64 {
+	if s.Weight <= 0 {
+		return 1
+	}
+	return s.Weight
+}
+
 type MergeConfig struct {
-	Cameras []string
+	Cameras []string      // points are used as is
+	Sources []MergeSource `json:"sources"`
+
+	TargetFrame string `json:"target_frame"` // defaults to world
+
+	FuseRadius float64 `json:"fuse_radius"` // if set, points from different cameras this close become one point
+
+	Refine *ICPConfig `json:"refine"` // if set, each camera is lined up with the ones before it with icp
+
+	TimeoutSeconds float64 `json:"timeout_seconds"` // per camera, 0 means no timeout
+	AllowPartial   bool    `json:"allow_partial"`   // if some cameras fail, merge the ones that worked
+}
+
+func (c *MergeConfig) timeout() time.Duration {
+	return time.Duration(c.TimeoutSeconds * float64(time.Second))
+}
+
+func (c *MergeConfig) targetFrame() string {
+	if c.TargetFrame == "" {
+		return referenceframe.World
+	}
+	return c.TargetFrame
+}
+
+// sources is Cameras and Sources together, in that order.
+func (c *MergeConfig) sources() []MergeSource {
+	all := []MergeSource{}
+	for _, cn := range c.Cameras {
+		all = append(all, MergeSource{Camera: cn})
+	}
+	return append(all, c.Sources...)
+}
+
+// frameNames is every frame needed to merge, empty if nothing has to be transformed.
+func (c *MergeConfig) frameNames() []string {
+	names := []string{}
+	for _, s := range c.Sources {
+		if s.Frame != "" && s.Frame != c.targetFrame() && !slices.Contains(names, s.Frame) {
+			names = append(names, s.Frame)
+		}
+	}
+	if len(names) > 0 {
+		names = append(names, c.targetFrame())
+	}
+	return names
 }
 
 func (c *MergeConfig) Validate(path string) ([]string, []string, error) {
-	if len(c.Cameras) == 0 {
+	if len(c.Cameras) == 0 && len(c.Sources) == 0 {
 		return nil, nil, fmt.Errorf("need cameras")
 	}
+	if c.TimeoutSeconds < 0 {
+		return nil, nil, fmt.Errorf("timeout_seconds can't be negative")
+	}
+	if c.FuseRadius < 0 {
+		return nil, nil, fmt.Errorf("fuse_radius can't be negative")
+	}
+	if c.Refine != nil {
+		err := c.Refine.Validate()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
 
-	return c.Cameras, nil, nil
+	deps := []string{}
+	for _, s := range c.sources() {
+		if s.Camera == "" {
+			return nil, nil, fmt.Errorf("source needs a camera")
+		}
+		deps = append(deps, s.Camera)
+	}
+
+	if len(c.frameNames()) > 0 {
+		deps = append(deps, framesystem.PublicServiceName.String())
+	}
+
+	return deps, nil, nil
 }
 
 func newMerge(ctx context.Context, deps resource.Dependencies, config resource.Config, logger logging.Logger) (camera.Camera, error) {
@@ -47,17 +133,27 @@ func newMerge(ctx context.Context, deps resource.Dependencies, config resource.C
 	cc := &MergeCamera{
 		name:    config.ResourceName(),
 		cfg:     newConf,
+		logger:  logger,
 		cameras: []camera.Camera{},
 	}
 
-	for _, cn := range newConf.Cameras {
-		c, err := camera.FromProvider(deps, cn)
+	cc.sources = newConf.sources()
+	for _, s := range cc.sources {
+		c, err := camera.FromProvider(deps, s.Camera)
 		if err != nil {
 			return nil, err
 		}
 		cc.cameras = append(cc.cameras, c)
 	}
 
+	if len(newConf.frameNames()) > 0 {
+		fs, err := framesystem.FromDependencies(deps)
+		if err != nil {
+			return nil, err
+		}
+		cc.transformer = NewLocalFrameTransformer(fs)
+	}
+
 	return cc, nil
 }
 
@@ -65,10 +161,46 @@ type MergeCamera struct {
 	resource.AlwaysRebuild
 	resource.TriviallyCloseable
 
-	name resource.Name
-	cfg  *MergeConfig
+	name   resource.Name
+	cfg    *MergeConfig
+	logger logging.Logger
+
+	sources     []MergeSource
+	cameras     []camera.Camera
+	transformer *LocalFrameTransformer // nil if no source needs transforming
+
+	statusLock sync.Mutex
+	lastStatus MergeStatus
+}
 
-	cameras []camera.Camera
+// MergeStatus says which sources made it into a merge, sources are indexes into MergeConfig.sources,
+// so the same camera with different frames is kept apart.
+type MergeStatus struct {
+	Time      time.Time
+	Succeeded []int
+	Failed    map[int]error
+	Fused     int             // points fused into another point
+	Residuals map[int]float64 // icp residual for each source that was refined
+}
+
+// toMap has an entry for each of sources, in order, with its camera, frame, and error or residual.
+func (s MergeStatus) toMap(sources []MergeSource) map[string]interface{} {
+	all := []interface{}{}
+	for i, src := range sources {
+		m := map[string]interface{}{"camera": src.Camera, "frame": src.Frame}
+		if err, ok := s.Failed[i]; ok {
+			m["error"] = err.Error()
+		}
+		if r, ok := s.Residuals[i]; ok {
+			m["residual"] = r
+		}
+		all = append(all, m)
+	}
+	return map[string]interface{}{
+		"time":    s.Time.Format(time.RFC3339Nano),
+		"sources": all,
+		"fused":   s.Fused,
+	}
 }
 
 func (mapc *MergeCamera) Name() resource.Name {
@@ -104,35 +236,154 @@ func (mapc *MergeCamera) Images(ctx context.Context, filterSourceNames []string,
 	return []camera.NamedImage{ni}, resource.ResponseMetadata{time.Now()}, nil
 }
 
+// DoCommand
+//
+//	{"status" : true} - which cameras succeeded and failed in the last merge to finish, from any caller,
+//	                    so with concurrent callers it may not be the merge a caller got, see NextPointCloudWithStatus
 func (mapc *MergeCamera) DoCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
-	return nil, nil
+	if cmd["status"] == true {
+		mapc.statusLock.Lock()
+		defer mapc.statusLock.Unlock()
+		return mapc.lastStatus.toMap(mapc.sources), nil
+	}
+	return nil, fmt.Errorf("unknown command %v", cmd)
+}
+
+// capture gets a point cloud from every camera at once, failed cameras have a nil point cloud and an error.
+func (mapc *MergeCamera) capture(ctx context.Context, extra map[string]interface{}) ([]pointcloud.PointCloud, []error) {
+	pcs := make([]pointcloud.PointCloud, len(mapc.cameras))
+	errs := make([]error, len(mapc.cameras))
+
+	wg := sync.WaitGroup{}
+	for i, c := range mapc.cameras {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			cctx := ctx
+			if mapc.cfg.timeout() > 0 {
+				var cancel context.CancelFunc
+				cctx, cancel = context.WithTimeout(ctx, mapc.cfg.timeout())
+				defer cancel()
+			}
+
+			// don't wait on a camera that ignores its context, buffered so it can always finish
+			type result struct {
+				pc  pointcloud.PointCloud
+				err error
+			}
+			results := make(chan result, 1)
+			go func() {
+				pc, err := c.NextPointCloud(cctx, extra)
+				results <- result{pc, err}
+			}()
+
+			select {
+			case r := <-results:
+				if r.err == nil && r.pc == nil {
+					r.err = fmt.Errorf("no point cloud")
+				}
+				pcs[i], errs[i] = r.pc, r.err
+			case <-cctx.Done():
+				errs[i] = cctx.Err()
+			}
+		}()
+	}
+	wg.Wait()
+
+	return pcs, errs
 }
 
 func (mapc *MergeCamera) NextPointCloud(ctx context.Context, extra map[string]interface{}) (pointcloud.PointCloud, error) {
-	inputs := []pointcloud.PointCloud{}
-	totalSize := 0
+	pc, _, err := mapc.NextPointCloudWithStatus(ctx, extra)
+	return pc, err
+}
 
-	for _, c := range mapc.cameras {
+// NextPointCloudWithStatus is NextPointCloud plus which cameras made it into this merge,
+// unlike the status DoCommand it's always this call's merge.
+func (mapc *MergeCamera) NextPointCloudWithStatus(ctx context.Context, extra map[string]interface{}) (pointcloud.PointCloud, MergeStatus, error) {
+	status := MergeStatus{Time: time.Now(), Succeeded: []int{}, Failed: map[int]error{}}
+	// set once it's complete, even if the merge fails, so one merge's status is never mixed with another's
+	defer func() {
+		mapc.statusLock.Lock()
+		defer mapc.statusLock.Unlock()
+		mapc.lastStatus = status
+	}()
+
+	pc, err := mapc.merge(ctx, extra, &status)
+	return pc, status, err
+}
 
-		pc, err := c.NextPointCloud(ctx, extra)
+// merge fills in status as it goes.
+func (mapc *MergeCamera) merge(ctx context.Context, extra map[string]interface{}, status *MergeStatus) (pointcloud.PointCloud, error) {
+	pcs, errs := mapc.capture(ctx, extra)
+
+	if mapc.transformer != nil {
+		err := mapc.transformer.Update(ctx, mapc.cfg.frameNames())
 		if err != nil {
 			return nil, err
 		}
+	}
 
-		totalSize += pc.Size()
+	inputs := []pointcloud.PointCloud{}
+	weights := []float64{}
+
+	for i, pc := range pcs {
+		s := mapc.sources[i]
+		// a source that can't be moved failed the same as one that couldn't capture
+		if errs[i] == nil && s.Frame != "" && s.Frame != mapc.cfg.targetFrame() {
+			var err error
+			pc, err = mapc.transformer.TransformPointCloud(pc, s.Frame, mapc.cfg.targetFrame())
+			if err != nil {
+				errs[i] = fmt.Errorf("cannot move from [%s] to [%s]: %w", s.Frame, mapc.cfg.targetFrame(), err)
+			}
+		}
+		if errs[i] != nil {
+			status.Failed[i] = errs[i]
+			continue
+		}
+		status.Succeeded = append(status.Succeeded, i)
 
 		inputs = append(inputs, pc)
+		weights = append(weights, s.weight())
 	}
 
-	big := pointcloud.NewBasicPointCloud(totalSize)
+	if len(status.Failed) > 0 {
+		if len(inputs) == 0 || !mapc.cfg.AllowPartial {
+			for i, err := range errs {
+				if err != nil {
+					return nil, fmt.Errorf("camera [%s] failed (%d of %d failed): %w", mapc.sources[i].Camera, len(status.Failed), len(pcs), err)
+				}
+			}
+		}
+		for i, err := range errs {
+			if err != nil {
+				mapc.logger.Warnf("merging %d of %d cameras without camera [%s] (source %d): %v", len(inputs), len(pcs), mapc.sources[i].Camera, i, err)
+			}
+		}
+	}
 
-	for _, pc := range inputs {
-		err := pointcloud.ApplyOffset(pc, nil, big)
-		if err != nil {
-			return nil, err
+	status.Residuals = map[int]float64{}
+	if mapc.cfg.Refine != nil {
+		var results []ICPResult
+		var refineErrs []error
+		inputs, results, refineErrs = RefinePointClouds(inputs, mapc.cfg.Refine)
+		for j, r := range results {
+			i := status.Succeeded[j]
+			if refineErrs[j] != nil {
+				mapc.logger.Warnf("couldn't refine camera [%s] (source %d): %v", mapc.sources[i].Camera, i, refineErrs[j])
+				continue
+			}
+			status.Residuals[i] = r.Residual
 		}
 	}
 
+	big, fused, err := MergePointClouds(inputs, weights, mapc.cfg.FuseRadius)
+	if err != nil {
+		return nil, err
+	}
+	status.Fused = fused
+
 	return big, nil
 }
 
@@ -0,0 +1,186 @@
+package touch
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/referenceframe"
+	"go.viam.com/rdk/robot/framesystem"
+	"go.viam.com/rdk/spatialmath"
+	"go.viam.com/test"
+)
+
+// fakePointCloudCamera only supports NextPointCloud.
+type fakePointCloudCamera struct {
+	camera.Camera
+	nextPointCloud func(context.Context, map[string]interface{}) (pointcloud.PointCloud, error)
+}
+
+func (f *fakePointCloudCamera) NextPointCloud(ctx context.Context, extra map[string]interface{}) (pointcloud.PointCloud, error) {
+	return f.nextPointCloud(ctx, extra)
+}
+
+func makeTestMergeCamera(t *testing.T, cfg *MergeConfig, fns ...func(context.Context, map[string]interface{}) (pointcloud.PointCloud, error)) *MergeCamera {
+	mc := &MergeCamera{cfg: cfg, logger: logging.NewTestLogger(t), sources: cfg.sources()}
+	for _, fn := range fns {
+		mc.cameras = append(mc.cameras, &fakePointCloudCamera{nextPointCloud: fn})
+	}
+	return mc
+}
+
+func onePointCloud(p r3.Vector) func(context.Context, map[string]interface{}) (pointcloud.PointCloud, error) {
+	return func(ctx context.Context, extra map[string]interface{}) (pointcloud.PointCloud, error) {
+		pc := pointcloud.NewBasicEmpty()
+		return pc, pc.Set(p, nil)
+	}
+}
+
+func TestMergeCamera(t *testing.T) {
+	ctx := context.Background()
+
+	slow := func(ctx context.Context, extra map[string]interface{}) (pointcloud.PointCloud, error) {
+		time.Sleep(time.Second) // ignores ctx on purpose
+		return pointcloud.NewBasicEmpty(), nil
+	}
+	broken := func(ctx context.Context, extra map[string]interface{}) (pointcloud.PointCloud, error) {
+		return nil, errors.New("broken")
+	}
+
+	cfg := &MergeConfig{Cameras: []string{"a", "b", "c", "d"}, TimeoutSeconds: .1}
+	mc := makeTestMergeCamera(t, cfg, onePointCloud(r3.Vector{X: 1}), onePointCloud(r3.Vector{X: 2}), slow, broken)
+
+	start := time.Now()
+	_, err := mc.NextPointCloud(ctx, nil)
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "[c]")
+	test.That(t, errors.Is(err, context.DeadlineExceeded), test.ShouldBeTrue)
+	test.That(t, time.Since(start), test.ShouldBeLessThan, time.Second/2)
+
+	cfg.AllowPartial = true
+	pc, err := mc.NextPointCloud(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, pc.Size(), test.ShouldEqual, 2)
+
+	// each call gets the status of its own merge
+	pc, status, err := mc.NextPointCloudWithStatus(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, pc.Size(), test.ShouldEqual, 2)
+	test.That(t, status.Succeeded, test.ShouldResemble, []int{0, 1})
+	test.That(t, len(status.Failed), test.ShouldEqual, 2)
+
+	res, err := mc.DoCommand(ctx, map[string]interface{}{"status": true})
+	test.That(t, err, test.ShouldBeNil)
+	sources := res["sources"].([]interface{})
+	test.That(t, len(sources), test.ShouldEqual, 4)
+	test.That(t, sources[0], test.ShouldResemble, map[string]interface{}{"camera": "a", "frame": ""})
+	test.That(t, sources[3].(map[string]interface{})["error"], test.ShouldEqual, "broken")
+
+	// nothing worked is always an error
+	mc = makeTestMergeCamera(t, &MergeConfig{Cameras: []string{"d"}, AllowPartial: true}, broken)
+	_, err = mc.NextPointCloud(ctx, nil)
+	test.That(t, err, test.ShouldNotBeNil)
+
+	_, err = mc.DoCommand(ctx, map[string]interface{}{"foo": true})
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func TestMergeCameraFrames(t *testing.T) {
+	ctx := context.Background()
+
+	cfg := &MergeConfig{
+		Cameras: []string{"a"},
+		Sources: []MergeSource{{Camera: "b", Frame: "cam"}, {Camera: "c", Frame: "bin"}},
+	}
+	deps, _, err := cfg.Validate("")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, deps, test.ShouldResemble, []string{"a", "b", "c", framesystem.PublicServiceName.String()})
+	test.That(t, cfg.frameNames(), test.ShouldResemble, []string{"cam", "bin", referenceframe.World})
+
+	fs := newFakeFrameSystemService(t)
+	fs.inputs = referenceframe.FrameSystemInputs{"gantry": {5, 7}}
+
+	mc := makeTestMergeCamera(t, cfg, onePointCloud(r3.Vector{X: 1}), onePointCloud(r3.Vector{X: 1}), onePointCloud(r3.Vector{X: 1}))
+	mc.transformer = NewLocalFrameTransformer(fs)
+
+	pc, err := mc.NextPointCloud(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, pc.Size(), test.ShouldEqual, 3)
+	for _, p := range []r3.Vector{{X: 1}, {X: 6, Y: 7, Z: 10}, {X: 101}} {
+		_, got := pc.At(p.X, p.Y, p.Z)
+		test.That(t, got, test.ShouldBeTrue)
+	}
+
+	// everything in the bin's frame
+	cfg.TargetFrame = "bin"
+	pc, err = mc.NextPointCloud(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	for _, p := range []r3.Vector{{X: 1}, {X: -94, Y: 7, Z: 10}} {
+		_, got := pc.At(p.X, p.Y, p.Z)
+		test.That(t, got, test.ShouldBeTrue)
+	}
+
+	// a source that can't be moved is left out like one that couldn't capture,
+	// and the same camera in two frames has two entries
+	cfg = &MergeConfig{Sources: []MergeSource{{Camera: "a", Frame: "cam"}, {Camera: "a", Frame: "bin"}}, AllowPartial: true}
+	fs.inputs = referenceframe.FrameSystemInputs{"gantry": {5000, 0}} // past the gantry's limits
+	mc = makeTestMergeCamera(t, cfg, onePointCloud(r3.Vector{X: 1}), onePointCloud(r3.Vector{X: 1}))
+	mc.transformer = NewLocalFrameTransformer(fs)
+
+	pc, status, err := mc.NextPointCloudWithStatus(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, pc.Size(), test.ShouldEqual, 1)
+	test.That(t, status.Succeeded, test.ShouldResemble, []int{1})
+	test.That(t, status.Failed[0], test.ShouldNotBeNil)
+
+	cfg.AllowPartial = false
+	_, err = mc.NextPointCloud(ctx, nil)
+	test.That(t, err, test.ShouldNotBeNil)
+
+	cfg = &MergeConfig{Cameras: []string{"a"}}
+	deps, _, err = cfg.Validate("")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, deps, test.ShouldResemble, []string{"a"})
+
+	_, _, err = (&MergeConfig{Sources: []MergeSource{{Frame: "x"}}}).Validate("")
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func TestMergeCameraRefine(t *testing.T) {
+	ctx := context.Background()
+
+	corner := makeCornerCloud(2)
+	shifted := pointcloud.NewBasicEmpty()
+	test.That(t, pointcloud.ApplyOffset(corner, spatialmath.NewPoseFromPoint(r3.Vector{X: 2, Z: 1}), shifted), test.ShouldBeNil)
+
+	cfg := &MergeConfig{Cameras: []string{"a", "b"}, FuseRadius: 1, Refine: &ICPConfig{MaxCorrespondenceDistance: 10, NormalRadius: 5}}
+	_, _, err := cfg.Validate("")
+	test.That(t, err, test.ShouldBeNil)
+
+	mc := makeTestMergeCamera(t, cfg,
+		func(context.Context, map[string]interface{}) (pointcloud.PointCloud, error) { return corner, nil },
+		func(context.Context, map[string]interface{}) (pointcloud.PointCloud, error) { return shifted, nil },
+	)
+
+	pc, err := mc.NextPointCloud(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	// once lined up, every point of b is fused with one from a
+	test.That(t, pc.Size(), test.ShouldEqual, corner.Size())
+
+	res, err := mc.DoCommand(ctx, map[string]interface{}{"status": true})
+	test.That(t, err, test.ShouldBeNil)
+	sources := res["sources"].([]interface{})
+	test.That(t, sources[0].(map[string]interface{})["residual"], test.ShouldEqual, 0)
+	test.That(t, sources[1].(map[string]interface{})["residual"], test.ShouldBeLessThan, .1)
+	test.That(t, res["fused"], test.ShouldEqual, corner.Size())
+
+	cfg.Refine = &ICPConfig{}
+	_, _, err = cfg.Validate("")
+	test.That(t, err, test.ShouldNotBeNil)
+}
@@ -1,15 +1,22 @@
 package touch
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
 	"fmt"
+	"slices"
+	"sync"
 	"time"
 
+	"go.viam.com/rdk/components/arm"
 	"go.viam.com/rdk/components/camera"
 	toggleswitch "go.viam.com/rdk/components/switch"
 	"go.viam.com/rdk/logging"
 	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/referenceframe"
 	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/robot/framesystem"
 	"go.viam.com/rdk/spatialmath"
 
 	"github.com/erh/vmodutils"
@@ -28,8 +35,29 @@ func init() {
 
 type MultipleArmPosesConfig struct {
 	Src          string
-	SleepSeconds float64 `json:"sleep_seconds"`
+	SleepSeconds float64       `json:"sleep_seconds"`
+	Settle       *SettleConfig `json:"settle"` // if set, sleep_seconds is only used if things don't settle in time
 	Positions    []string
+
+	// if ReturnToStart is set, Arm's joints are saved before a scan and it goes back there after, even if the scan fails
+	Arm           string `json:"arm"`
+	ReturnToStart bool   `json:"return_to_start"`
+	Home          string `json:"home"` // arm-position-saver visited between positions
+
+	// each capture is moved from SrcFrame to OutputFrame using where the arm was when it was taken,
+	// setting them to the same frame uses captures as is, like world if src already returns world points
+	SrcFrame    string `json:"src_frame"`    // defaults to Src
+	OutputFrame string `json:"output_frame"` // defaults to world
+
+	FuseRadius float64 `json:"fuse_radius"` // if set, points from different positions this close become one point
+
+	Refine *ICPConfig `json:"refine"` // if set, each capture is lined up with the ones before it with icp
+
+	MaxAgeSeconds float64 `json:"max_age_seconds"` // if set, NextPointCloud returns the last full scan if it's newer than this
+}
+
+func (c *MultipleArmPosesConfig) maxAge() time.Duration {
+	return time.Duration(c.MaxAgeSeconds * float64(time.Second))
 }
 
 func (c *MultipleArmPosesConfig) sleepTime() time.Duration {
@@ -39,6 +67,28 @@ func (c *MultipleArmPosesConfig) sleepTime() time.Duration {
 	return time.Duration(c.SleepSeconds * float64(time.Second))
 }
 
+func (c *MultipleArmPosesConfig) srcFrame() string {
+	if c.SrcFrame != "" {
+		return c.SrcFrame
+	}
+	return c.Src
+}
+
+func (c *MultipleArmPosesConfig) outputFrame() string {
+	if c.OutputFrame != "" {
+		return c.OutputFrame
+	}
+	return referenceframe.World
+}
+
+// frameNames is every frame needed to move captures, empty if src_frame and output_frame are the same, so they're used as is.
+func (c *MultipleArmPosesConfig) frameNames() []string {
+	if c.srcFrame() == c.outputFrame() {
+		return []string{}
+	}
+	return []string{c.srcFrame(), c.outputFrame()}
+}
+
 func (c *MultipleArmPosesConfig) Validate(path string) ([]string, []string, error) {
 	if c.Src == "" {
 		return nil, nil, fmt.Errorf("need a src camera")
@@ -48,7 +98,45 @@ func (c *MultipleArmPosesConfig) Validate(path string) ([]string, []string, erro
 		return nil, nil, fmt.Errorf("no positions")
 	}
 
-	return append(c.Positions, c.Src), nil, nil
+	if c.FuseRadius < 0 {
+		return nil, nil, fmt.Errorf("fuse_radius can't be negative")
+	}
+
+	if c.MaxAgeSeconds < 0 {
+		return nil, nil, fmt.Errorf("max_age_seconds can't be negative")
+	}
+
+	if c.Refine != nil {
+		err := c.Refine.Validate()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if c.Settle != nil {
+		err := c.Settle.Validate()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if c.ReturnToStart && c.Arm == "" {
+		return nil, nil, fmt.Errorf("return_to_start needs an arm")
+	}
+
+	deps := append([]string{}, c.Positions...)
+	deps = append(deps, c.Src)
+	if len(c.frameNames()) > 0 {
+		deps = append(deps, framesystem.PublicServiceName.String())
+	}
+	if c.Arm != "" {
+		deps = append(deps, c.Arm)
+	}
+	if c.Home != "" {
+		deps = append(deps, c.Home)
+	}
+
+	return deps, nil, nil
 }
 
 func newMultipleArmPoses(ctx context.Context, deps resource.Dependencies, config resource.Config, logger logging.Logger) (camera.Camera, error) {
@@ -60,6 +148,7 @@ func newMultipleArmPoses(ctx context.Context, deps resource.Dependencies, config
 	cc := &MultipleArmPosesCamera{
 		name:      config.ResourceName(),
 		cfg:       newConf,
+		logger:    logger,
 		positions: []toggleswitch.Switch{},
 	}
 
@@ -76,6 +165,28 @@ func newMultipleArmPoses(ctx context.Context, deps resource.Dependencies, config
 		cc.positions = append(cc.positions, s)
 	}
 
+	if newConf.Arm != "" {
+		cc.arm, err = arm.FromProvider(deps, newConf.Arm)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if newConf.Home != "" {
+		cc.home, err = toggleswitch.FromProvider(deps, newConf.Home)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(newConf.frameNames()) > 0 {
+		fs, err := framesystem.FromDependencies(deps)
+		if err != nil {
+			return nil, err
+		}
+		cc.transformer = NewLocalFrameTransformer(fs)
+	}
+
 	return cc, nil
 }
 
@@ -83,11 +194,24 @@ type MultipleArmPosesCamera struct {
 	resource.AlwaysRebuild
 	resource.TriviallyCloseable
 
-	name resource.Name
-	cfg  *MultipleArmPosesConfig
+	name   resource.Name
+	cfg    *MultipleArmPosesConfig
+	logger logging.Logger
+
+	src         camera.Camera
+	positions   []toggleswitch.Switch
+	transformer *LocalFrameTransformer // nil if captures are used as is
+	arm         arm.Arm                // nil if not configured
+	home        toggleswitch.Switch    // nil if not configured
 
-	src       camera.Camera
-	positions []toggleswitch.Switch
+	scanLock sync.Mutex // only one scan moves the arm at a time
+
+	fullScans vmodutils.SingleFlight[pointcloud.PointCloud] // concurrent full scans wait for the one in progress
+
+	statusLock    sync.Mutex
+	lastFused     int
+	lastResiduals []float64 // per position, -1 if it couldn't be refined
+	lastTimings   []map[string]interface{}
 }
 
 func (mapc *MultipleArmPosesCamera) Name() resource.Name {
@@ -102,12 +226,199 @@ func (mapc *MultipleArmPosesCamera) Images(ctx context.Context, _ []string, _ ma
 	return nil, resource.ResponseMetadata{}, fmt.Errorf("image not supported")
 }
 
+// DoCommand
+//
+//	{"status" : true} - how many points were fused in the last merge, the icp residual for each position if refining,
+//	                    and how long each position took to move to and settle
+//	{"scan" : ["<position>", ...]} - scans just those positions, returns the number of points and the point cloud as base64 binary pcd
+//	{"rescan" : true} - does a full scan even if the last one is fresh, after any scan already running, returns the same as scan
+//	{"get_cached" : true} - returns the last full scan without moving, the same as scan plus when it was taken
 func (mapc *MultipleArmPosesCamera) DoCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
-	return nil, nil
+	if cmd["status"] == true {
+		mapc.statusLock.Lock()
+		defer mapc.statusLock.Unlock()
+		return map[string]interface{}{"fused": mapc.lastFused, "residuals": mapc.lastResiduals, "timings": mapc.lastTimings}, nil
+	}
+
+	if names, ok := cmd["scan"]; ok {
+		idxs, err := mapc.positionIndexes(names)
+		if err != nil {
+			return nil, err
+		}
+
+		pc, err := mapc.scan(ctx, idxs, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		return pointCloudResponse(pc)
+	}
+
+	if cmd["rescan"] == true {
+		pc, err := mapc.fullScans.DoFresh(ctx, mapc.fullScanFunc(nil))
+		if err != nil {
+			return nil, err
+		}
+
+		return pointCloudResponse(pc)
+	}
+
+	if cmd["get_cached"] == true {
+		pc, when, ok := mapc.fullScans.Last()
+		if !ok {
+			return nil, fmt.Errorf("no scan cached")
+		}
+
+		res, err := pointCloudResponse(pc)
+		if err != nil {
+			return nil, err
+		}
+		res["time"] = when.Format(time.RFC3339Nano)
+		return res, nil
+	}
+
+	return nil, fmt.Errorf("unknown command %v", cmd)
 }
 
+// pointCloudResponse is a DoCommand response with the number of points and pc as base64 binary pcd.
+func pointCloudResponse(pc pointcloud.PointCloud) (map[string]interface{}, error) {
+	buf := bytes.Buffer{}
+	err := pointcloud.ToPCD(pc, &buf, pointcloud.PCDBinary)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"points": pc.Size(),
+		"pcd":    base64.StdEncoding.EncodeToString(buf.Bytes()),
+	}, nil
+}
+
+// positionIndexes turns a list of position names from a DoCommand into indexes into Positions.
+func (mapc *MultipleArmPosesCamera) positionIndexes(names interface{}) ([]int, error) {
+	list, ok := names.([]interface{})
+	if !ok || len(list) == 0 {
+		return nil, fmt.Errorf("need a list of positions, not %v", names)
+	}
+
+	idxs := []int{}
+	for _, n := range list {
+		idx := slices.Index(mapc.cfg.Positions, fmt.Sprintf("%v", n))
+		if idx < 0 {
+			return nil, fmt.Errorf("unknown position [%v]", n)
+		}
+		idxs = append(idxs, idx)
+	}
+	return idxs, nil
+}
+
+// NextPointCloud returns the last full scan if it's newer than max_age_seconds, otherwise it scans.
 func (mapc *MultipleArmPosesCamera) NextPointCloud(ctx context.Context, extra map[string]interface{}) (pointcloud.PointCloud, error) {
-	return GetMergedPointCloud(ctx, mapc.positions, mapc.cfg.sleepTime(), mapc.src, extra)
+	return mapc.fullScans.Get(ctx, mapc.cfg.maxAge(), mapc.fullScanFunc(extra))
+}
+
+// fullScanFunc scans every position.
+func (mapc *MultipleArmPosesCamera) fullScanFunc(extra map[string]interface{}) func(context.Context) (pointcloud.PointCloud, error) {
+	return func(ctx context.Context) (pointcloud.PointCloud, error) {
+		idxs := make([]int, len(mapc.positions))
+		for i := range idxs {
+			idxs[i] = i
+		}
+		return mapc.scan(ctx, idxs, extra)
+	}
+}
+
+// scan captures at each of the positions at idxs, in order, and merges them.
+func (mapc *MultipleArmPosesCamera) scan(ctx context.Context, idxs []int, extra map[string]interface{}) (pointcloud.PointCloud, error) {
+	mapc.scanLock.Lock()
+	defer mapc.scanLock.Unlock()
+
+	if mapc.cfg.ReturnToStart {
+		start, err := mapc.arm.JointPositions(ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+		defer mapc.returnTo(ctx, start)
+	}
+
+	positions := []toggleswitch.Switch{}
+	names := []string{}
+	for _, i := range idxs {
+		positions = append(positions, mapc.positions[i])
+		names = append(names, mapc.cfg.Positions[i])
+	}
+
+	opts := CaptureOptions{
+		SleepTime: mapc.cfg.sleepTime(),
+		Extra:     extra,
+		Settle:    mapc.cfg.Settle,
+		Arm:       mapc.arm,
+		Logger:    mapc.logger,
+		Home:      mapc.home,
+	}
+	if mapc.transformer != nil {
+		opts.OnCapture = mapc.toOutputFrame
+	}
+
+	pcs, timings, err := CapturePointCloudsWithOptions(ctx, positions, mapc.src, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	timingMaps := []map[string]interface{}{}
+	for i, t := range timings {
+		m := t.toMap()
+		m["position"] = names[i]
+		timingMaps = append(timingMaps, m)
+	}
+
+	var residuals []float64
+	if mapc.cfg.Refine != nil {
+		var results []ICPResult
+		var errs []error
+		pcs, results, errs = RefinePointClouds(pcs, mapc.cfg.Refine)
+		for i, r := range results {
+			if errs[i] != nil {
+				mapc.logger.Warnf("couldn't refine position [%s]: %v", names[i], errs[i])
+				residuals = append(residuals, -1)
+				continue
+			}
+			residuals = append(residuals, r.Residual)
+		}
+	}
+
+	pc, fused, err := MergePointClouds(pcs, nil, mapc.cfg.FuseRadius)
+	if err != nil {
+		return nil, err
+	}
+
+	mapc.statusLock.Lock()
+	mapc.lastFused = fused
+	mapc.lastResiduals = residuals
+	mapc.lastTimings = timingMaps
+	mapc.statusLock.Unlock()
+
+	return pc, nil
+}
+
+// returnTo moves the arm back to joints, even if ctx was canceled.
+func (mapc *MultipleArmPosesCamera) returnTo(ctx context.Context, joints []referenceframe.Input) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), time.Minute)
+	defer cancel()
+
+	err := mapc.arm.MoveToJointPositions(ctx, joints, nil)
+	if err != nil {
+		mapc.logger.Warnf("couldn't return to start: %v", err)
+	}
+}
+
+// toOutputFrame moves a capture to the output frame, it has to be called right after the capture, before the arm moves again.
+func (mapc *MultipleArmPosesCamera) toOutputFrame(ctx context.Context, pc pointcloud.PointCloud) (pointcloud.PointCloud, error) {
+	err := mapc.transformer.Update(ctx, mapc.cfg.frameNames())
+	if err != nil {
+		return nil, err
+	}
+	return mapc.transformer.TransformPointCloud(pc, mapc.cfg.srcFrame(), mapc.cfg.outputFrame())
 }
 
 func (mapc *MultipleArmPosesCamera) Properties(ctx context.Context) (camera.Properties, error) {
@@ -0,0 +1,295 @@
+package touch
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/components/arm"
+	toggleswitch "go.viam.com/rdk/components/switch"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/referenceframe"
+	"go.viam.com/rdk/robot/framesystem"
+	"go.viam.com/test"
+)
+
+// fakeSwitch only supports SetPosition.
+type fakeSwitch struct {
+	toggleswitch.Switch
+	setPosition func(position uint32)
+}
+
+func (f *fakeSwitch) SetPosition(ctx context.Context, position uint32, extra map[string]interface{}) error {
+	f.setPosition(position)
+	return nil
+}
+
+func TestMultipleArmPosesOutputFrame(t *testing.T) {
+	ctx := context.Background()
+	fs := newFakeFrameSystemService(t)
+
+	// each position moves the gantry the camera is on
+	gantryAt := func(x, y float64) toggleswitch.Switch {
+		return &fakeSwitch{setPosition: func(uint32) {
+			fs.inputs = referenceframe.FrameSystemInputs{"gantry": {referenceframe.Input(x), referenceframe.Input(y)}}
+		}}
+	}
+
+	// src_frame the same as output_frame uses captures as is, so no frame system
+	cfg := &MultipleArmPosesConfig{Src: "cam", SrcFrame: referenceframe.World, SleepSeconds: .001, Positions: []string{"a", "b"}}
+	deps, _, err := cfg.Validate("")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, deps, test.ShouldResemble, []string{"a", "b", "cam"})
+
+	passThrough := &MultipleArmPosesCamera{
+		cfg:       cfg,
+		logger:    logging.NewTestLogger(t),
+		src:       &fakePointCloudCamera{nextPointCloud: onePointCloud(r3.Vector{X: 1})},
+		positions: []toggleswitch.Switch{gantryAt(0, 0), gantryAt(50, 20)},
+	}
+	pc, err := passThrough.NextPointCloud(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, pc.Size(), test.ShouldEqual, 1)
+	_, got := pc.At(1, 0, 0)
+	test.That(t, got, test.ShouldBeTrue)
+
+	// by default captures are moved from src's frame to world
+	cfg = &MultipleArmPosesConfig{Src: "cam", SleepSeconds: .001, Positions: []string{"a", "b"}}
+	deps, _, err = cfg.Validate("")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, deps, test.ShouldResemble, []string{"a", "b", "cam", framesystem.PublicServiceName.String()})
+	test.That(t, cfg.frameNames(), test.ShouldResemble, []string{"cam", referenceframe.World})
+
+	mapc := &MultipleArmPosesCamera{
+		cfg:         cfg,
+		logger:      logging.NewTestLogger(t),
+		src:         &fakePointCloudCamera{nextPointCloud: onePointCloud(r3.Vector{X: 1})},
+		positions:   []toggleswitch.Switch{gantryAt(0, 0), gantryAt(50, 20)},
+		transformer: NewLocalFrameTransformer(fs),
+	}
+
+	pc, err = mapc.NextPointCloud(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, pc.Size(), test.ShouldEqual, 2)
+	for _, p := range []r3.Vector{{X: 1, Z: 10}, {X: 51, Y: 20, Z: 10}} {
+		_, got := pc.At(p.X, p.Y, p.Z)
+		test.That(t, got, test.ShouldBeTrue)
+	}
+
+	cfg.OutputFrame = "bin"
+	pc, err = mapc.NextPointCloud(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	_, got = pc.At(-49, 20, 10)
+	test.That(t, got, test.ShouldBeTrue)
+
+	// src already in world
+	cfg.OutputFrame = ""
+	cfg.SrcFrame = referenceframe.World
+	pc, err = mapc.NextPointCloud(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, pc.Size(), test.ShouldEqual, 1)
+}
+
+// fakeArm only supports JointPositions, MoveToJointPositions, MoveThroughJointPositions, and IsMoving.
+type fakeArm struct {
+	arm.Arm
+	joints  []referenceframe.Input
+	moves   int
+	moving  int              // how many more times IsMoving says true
+	options *arm.MoveOptions // from the last MoveThroughJointPositions
+}
+
+func (f *fakeArm) MoveThroughJointPositions(ctx context.Context, positions [][]referenceframe.Input, options *arm.MoveOptions, extra map[string]interface{}) error {
+	f.options = options
+	for _, p := range positions {
+		err := f.MoveToJointPositions(ctx, p, extra)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *fakeArm) IsMoving(ctx context.Context) (bool, error) {
+	if f.moving > 0 {
+		f.moving--
+		return true, nil
+	}
+	return false, nil
+}
+
+func (f *fakeArm) JointPositions(ctx context.Context, extra map[string]interface{}) ([]referenceframe.Input, error) {
+	return f.joints, nil
+}
+
+func (f *fakeArm) MoveToJointPositions(ctx context.Context, positions []referenceframe.Input, extra map[string]interface{}) error {
+	f.joints = positions
+	f.moves++
+	return nil
+}
+
+func TestMultipleArmPosesSequencing(t *testing.T) {
+	ctx := context.Background()
+	fs := newFakeFrameSystemService(t)
+
+	visited := []string{}
+	position := func(name string) toggleswitch.Switch {
+		return &fakeSwitch{setPosition: func(uint32) { visited = append(visited, name) }}
+	}
+
+	captures := 0
+	src := &fakePointCloudCamera{nextPointCloud: func(ctx context.Context, extra map[string]interface{}) (pointcloud.PointCloud, error) {
+		captures++
+		if captures == 3 {
+			return nil, errors.New("camera broke")
+		}
+		pc := pointcloud.NewBasicEmpty()
+		return pc, pc.Set(r3.Vector{X: float64(captures)}, nil)
+	}}
+
+	cfg := &MultipleArmPosesConfig{
+		Src:           "cam",
+		SrcFrame:      referenceframe.World,
+		SleepSeconds:  .001,
+		Positions:     []string{"a", "b", "c"},
+		Arm:           "arm",
+		ReturnToStart: true,
+		Home:          "home",
+	}
+	deps, _, err := cfg.Validate("")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, deps, test.ShouldContain, "arm")
+	test.That(t, deps, test.ShouldContain, "home")
+
+	a := &fakeArm{joints: []referenceframe.Input{1, 2, 3}}
+	mapc := &MultipleArmPosesCamera{
+		cfg:         cfg,
+		logger:      logging.NewTestLogger(t),
+		src:         src,
+		positions:   []toggleswitch.Switch{position("a"), position("b"), position("c")},
+		transformer: NewLocalFrameTransformer(fs),
+		arm:         a,
+		home:        position("home"),
+	}
+
+	// fails on the last position, but still goes back to the start
+	_, err = mapc.NextPointCloud(ctx, nil)
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, visited, test.ShouldResemble, []string{"a", "home", "b", "home", "c"})
+	test.That(t, a.moves, test.ShouldEqual, 1)
+	test.That(t, a.joints, test.ShouldResemble, []referenceframe.Input{1, 2, 3})
+
+	visited = []string{}
+	res, err := mapc.DoCommand(ctx, map[string]interface{}{"scan": []interface{}{"c", "a"}})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, visited, test.ShouldResemble, []string{"c", "home", "a"})
+	test.That(t, res["points"], test.ShouldEqual, 2)
+	test.That(t, a.moves, test.ShouldEqual, 2)
+
+	data, err := base64.StdEncoding.DecodeString(res["pcd"].(string))
+	test.That(t, err, test.ShouldBeNil)
+	pc, err := pointcloud.ReadPCD(bytes.NewReader(data), "")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, pc.Size(), test.ShouldEqual, 2)
+
+	_, err = mapc.DoCommand(ctx, map[string]interface{}{"scan": []interface{}{"x"}})
+	test.That(t, err, test.ShouldNotBeNil)
+	_, err = mapc.DoCommand(ctx, map[string]interface{}{"scan": "a"})
+	test.That(t, err, test.ShouldNotBeNil)
+
+	cfg.Arm = ""
+	_, _, err = cfg.Validate("")
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func TestMultipleArmPosesCache(t *testing.T) {
+	ctx := context.Background()
+	fs := newFakeFrameSystemService(t)
+
+	var capturesLock sync.Mutex
+	captures := 0
+	release := make(chan struct{})
+	src := &fakePointCloudCamera{nextPointCloud: func(ctx context.Context, extra map[string]interface{}) (pointcloud.PointCloud, error) {
+		<-release
+		capturesLock.Lock()
+		captures++
+		capturesLock.Unlock()
+		pc := pointcloud.NewBasicEmpty()
+		return pc, pc.Set(r3.Vector{}, nil)
+	}}
+	getCaptures := func() int {
+		capturesLock.Lock()
+		defer capturesLock.Unlock()
+		return captures
+	}
+
+	cfg := &MultipleArmPosesConfig{
+		Src:           "cam",
+		SrcFrame:      referenceframe.World,
+		SleepSeconds:  .001,
+		Positions:     []string{"a"},
+		MaxAgeSeconds: 60,
+	}
+	_, _, err := cfg.Validate("")
+	test.That(t, err, test.ShouldBeNil)
+
+	mapc := &MultipleArmPosesCamera{
+		cfg:         cfg,
+		logger:      logging.NewTestLogger(t),
+		src:         src,
+		positions:   []toggleswitch.Switch{&fakeSwitch{setPosition: func(uint32) {}}},
+		transformer: NewLocalFrameTransformer(fs),
+	}
+
+	_, err = mapc.DoCommand(ctx, map[string]interface{}{"get_cached": true})
+	test.That(t, err, test.ShouldNotBeNil)
+
+	// concurrent callers share one scan
+	wg := sync.WaitGroup{}
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pc, err := mapc.NextPointCloud(ctx, nil)
+			test.That(t, err, test.ShouldBeNil)
+			test.That(t, pc.Size(), test.ShouldEqual, 1)
+		}()
+	}
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	test.That(t, getCaptures(), test.ShouldEqual, 1)
+
+	// fresh, so no scan
+	_, err = mapc.NextPointCloud(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, getCaptures(), test.ShouldEqual, 1)
+
+	res, err := mapc.DoCommand(ctx, map[string]interface{}{"get_cached": true})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, res["points"], test.ShouldEqual, 1)
+	test.That(t, res["time"], test.ShouldNotBeEmpty)
+	test.That(t, getCaptures(), test.ShouldEqual, 1)
+
+	_, err = mapc.DoCommand(ctx, map[string]interface{}{"rescan": true})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, getCaptures(), test.ShouldEqual, 2)
+
+	// stale
+	cfg.MaxAgeSeconds = .001
+	time.Sleep(5 * time.Millisecond)
+	_, err = mapc.NextPointCloud(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, getCaptures(), test.ShouldEqual, 3)
+
+	cfg.MaxAgeSeconds = -1
+	_, _, err = cfg.Validate("")
+	test.That(t, err, test.ShouldNotBeNil)
+}
@@ -0,0 +1,143 @@
+package touch
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/rimage"
+	"go.viam.com/rdk/spatialmath"
+
+	"github.com/erh/vmodutils"
+)
+
+var VoxelFilterCameraModel = vmodutils.NamespaceFamily.WithModel("pc-voxel-filter")
+
+func init() {
+	resource.RegisterComponent(
+		camera.API,
+		VoxelFilterCameraModel,
+		resource.Registration[camera.Camera, *VoxelFilterCameraConfig]{
+			Constructor: newVoxelFilterCamera,
+		})
+}
+
+type VoxelFilterCameraConfig struct {
+	Src       string  `json:"src"`
+	VoxelSize float64 `json:"voxel_size"`
+	Mode      string  `json:"mode"` // centroid (default) or nearest
+}
+
+func (c *VoxelFilterCameraConfig) Validate(path string) ([]string, []string, error) {
+	if c.Src == "" {
+		return nil, nil, fmt.Errorf("need a src camera")
+	}
+	if c.VoxelSize <= 0 {
+		return nil, nil, fmt.Errorf("need a voxel_size")
+	}
+	if c.Mode != "" && c.Mode != VoxelModeCentroid && c.Mode != VoxelModeNearest {
+		return nil, nil, fmt.Errorf("unknown mode [%s]", c.Mode)
+	}
+	return []string{c.Src}, nil, nil
+}
+
+func newVoxelFilterCamera(ctx context.Context, deps resource.Dependencies, config resource.Config, logger logging.Logger) (camera.Camera, error) {
+	newConf, err := resource.NativeConfig[*VoxelFilterCameraConfig](config)
+	if err != nil {
+		return nil, err
+	}
+
+	vfc := &VoxelFilterCamera{
+		name:   config.ResourceName(),
+		cfg:    newConf,
+		logger: logger,
+	}
+
+	vfc.src, err = camera.FromProvider(deps, newConf.Src)
+	if err != nil {
+		return nil, err
+	}
+
+	return vfc, nil
+}
+
+type VoxelFilterCamera struct {
+	resource.AlwaysRebuild
+	resource.TriviallyCloseable
+
+	name   resource.Name
+	cfg    *VoxelFilterCameraConfig
+	logger logging.Logger
+
+	src camera.Camera
+}
+
+func (vfc *VoxelFilterCamera) Name() resource.Name {
+	return vfc.name
+}
+
+func (vfc *VoxelFilterCamera) Image(ctx context.Context, mimeType string, extra map[string]interface{}) ([]byte, camera.ImageMetadata, error) {
+	pc, err := vfc.NextPointCloud(ctx, extra)
+	if err != nil {
+		return nil, camera.ImageMetadata{}, err
+	}
+	img := PCToImage(pc)
+
+	data, err := rimage.EncodeImage(ctx, img, mimeType)
+	if err != nil {
+		return nil, camera.ImageMetadata{}, err
+	}
+
+	return data, camera.ImageMetadata{MimeType: mimeType}, err
+}
+
+func (vfc *VoxelFilterCamera) Images(ctx context.Context, filterSourceNames []string, extra map[string]interface{}) ([]camera.NamedImage, resource.ResponseMetadata, error) {
+	pc, err := vfc.NextPointCloud(ctx, extra)
+	if err != nil {
+		return nil, resource.ResponseMetadata{}, err
+	}
+	img := PCToImage(pc)
+
+	ni, err := camera.NamedImageFromImage(img, "filtered", "image/png")
+	if err != nil {
+		return nil, resource.ResponseMetadata{}, err
+	}
+	return []camera.NamedImage{ni}, resource.ResponseMetadata{CapturedAt: time.Now()}, nil
+}
+
+func (vfc *VoxelFilterCamera) DoCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	return nil, nil
+}
+
+func (vfc *VoxelFilterCamera) NextPointCloud(ctx context.Context, extra map[string]interface{}) (pointcloud.PointCloud, error) {
+	pc, err := vfc.src.NextPointCloud(ctx, extra)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	out, err := PCVoxelDownsample(pc, vfc.cfg.VoxelSize, vfc.cfg.Mode)
+	if err != nil {
+		return nil, err
+	}
+
+	if time.Since(start) > (time.Millisecond * 250) {
+		vfc.logger.Infof("VoxelFilterCamera::NextPointCloud %d -> %d points took %v", pc.Size(), out.Size(), time.Since(start))
+	}
+
+	return out, nil
+}
+
+func (vfc *VoxelFilterCamera) Properties(ctx context.Context) (camera.Properties, error) {
+	return camera.Properties{
+		SupportsPCD: true,
+	}, nil
+}
+
+func (vfc *VoxelFilterCamera) Geometries(ctx context.Context, _ map[string]interface{}) ([]spatialmath.Geometry, error) {
+	return nil, nil
+}
@@ -0,0 +1,223 @@
+package touch
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/pointcloud"
+)
+
+// Plane is every point p where Normal.Dot(p) + Offset == 0, Normal is unit length.
+type Plane struct {
+	Normal r3.Vector
+	Offset float64
+}
+
+// Distance returns how far pt is from the plane.
+func (p Plane) Distance(pt r3.Vector) float64 {
+	return math.Abs(p.Normal.Dot(pt) + p.Offset)
+}
+
+func planeFromPoints(a, b, c r3.Vector) (Plane, bool) {
+	n := b.Sub(a).Cross(c.Sub(a))
+	if n.Norm2() < 1e-12 {
+		return Plane{}, false
+	}
+	n = n.Normalize()
+	return Plane{Normal: n, Offset: -n.Dot(a)}, true
+}
+
+// fitPlane does a least squares fit of a plane to points.
+func fitPlane(points []r3.Vector) (Plane, bool) {
+	n, _, ok := estimateNormal(points)
+	if !ok {
+		return Plane{}, false
+	}
+
+	mean := r3.Vector{}
+	for _, p := range points {
+		mean = mean.Add(p)
+	}
+	mean = mean.Mul(1 / float64(len(points)))
+
+	return Plane{Normal: n, Offset: -n.Dot(mean)}, true
+}
+
+func countPlaneInliers(plane Plane, points []r3.Vector, threshold float64) int {
+	count := 0
+	for _, p := range points {
+		if plane.Distance(p) <= threshold {
+			count++
+		}
+	}
+	return count
+}
+
+// ransacPlane returns the plane with the most points within threshold.
+func ransacPlane(points []r3.Vector, threshold float64, iterations int) (Plane, error) {
+	if len(points) < 3 {
+		return Plane{}, fmt.Errorf("%w, need at least 3 points, have %d", ErrNoPlane, len(points))
+	}
+
+	r := rand.New(rand.NewSource(1))
+
+	best := Plane{}
+	bestCount := -1
+
+	for i := 0; i < iterations; i++ {
+		plane, ok := planeFromPoints(
+			points[r.Intn(len(points))],
+			points[r.Intn(len(points))],
+			points[r.Intn(len(points))],
+		)
+		if !ok {
+			continue
+		}
+
+		count := countPlaneInliers(plane, points, threshold)
+		if count > bestCount {
+			best = plane
+			bestCount = count
+		}
+	}
+
+	if bestCount < 0 {
+		return Plane{}, fmt.Errorf("%w in %d points, are they all in a line?", ErrNoPlane, len(points))
+	}
+
+	// refine with all the inliers, keeping the sampled plane if that's worse
+	inliers := []r3.Vector{}
+	for _, p := range points {
+		if best.Distance(p) <= threshold {
+			inliers = append(inliers, p)
+		}
+	}
+
+	refined, ok := fitPlane(inliers)
+	if ok && countPlaneInliers(refined, points, threshold) >= bestCount {
+		best = refined
+	}
+
+	return best, nil
+}
+
+// ErrNoPlane is returned when there aren't enough points, or they're degenerate, like all in a line.
+var ErrNoPlane = errors.New("couldn't find a plane")
+
+// SegmentPlane fits the dominant plane in pc with RANSAC.
+// Returns the plane, the points within threshold of it, and everything else.
+func SegmentPlane(pc pointcloud.PointCloud, threshold float64, iterations int) (Plane, pointcloud.PointCloud, pointcloud.PointCloud, error) {
+	if threshold <= 0 {
+		return Plane{}, nil, nil, fmt.Errorf("threshold has to be positive, not %v", threshold)
+	}
+	if iterations <= 0 {
+		return Plane{}, nil, nil, fmt.Errorf("iterations has to be positive, not %v", iterations)
+	}
+
+	points := make([]r3.Vector, 0, pc.Size())
+	pc.Iterate(0, 0, func(p r3.Vector, d pointcloud.Data) bool {
+		points = append(points, p)
+		return true
+	})
+
+	plane, err := ransacPlane(points, threshold, iterations)
+	if err != nil {
+		return Plane{}, nil, nil, err
+	}
+
+	inliers := pointcloud.NewBasicEmpty()
+	outliers := pointcloud.NewBasicEmpty()
+
+	pc.Iterate(0, 0, func(p r3.Vector, d pointcloud.Data) bool {
+		if plane.Distance(p) <= threshold {
+			err = inliers.Set(p, d)
+		} else {
+			err = outliers.Set(p, d)
+		}
+		return err == nil
+	})
+	if err != nil {
+		return Plane{}, nil, nil, err
+	}
+
+	return plane, inliers, outliers, nil
+}
+
+// PlaneSegment is one plane found by SegmentPlanes.
+type PlaneSegment struct {
+	Plane   Plane
+	Inliers pointcloud.PointCloud
+}
+
+// SegmentPlanes repeatedly removes the dominant plane, biggest first, until maxPlanes are found
+// or the next plane has fewer than minInliers points.
+// Returns the planes and the points that aren't on any of them.
+func SegmentPlanes(pc pointcloud.PointCloud, threshold float64, iterations, maxPlanes, minInliers int) ([]PlaneSegment, pointcloud.PointCloud, error) {
+	planes := []PlaneSegment{}
+	remaining := pc
+
+	for len(planes) < maxPlanes && remaining.Size() >= 3 {
+		plane, inliers, outliers, err := SegmentPlane(remaining, threshold, iterations)
+		if errors.Is(err, ErrNoPlane) {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if inliers.Size() < minInliers {
+			break
+		}
+
+		planes = append(planes, PlaneSegment{plane, inliers})
+		remaining = outliers
+	}
+
+	return planes, remaining, nil
+}
+
+// PlaneRemovalConfig is for stripping the table out of a point cloud.
+type PlaneRemovalConfig struct {
+	Distance   float64 `json:"distance"`    // points this close (mm) to the plane are removed
+	Iterations int     `json:"iterations"`  // RANSAC iterations, defaults to 200
+	MinInliers int     `json:"min-inliers"` // only remove the plane if it has at least this many points
+}
+
+func (c *PlaneRemovalConfig) iterations() int {
+	if c.Iterations <= 0 {
+		return 200
+	}
+	return c.Iterations
+}
+
+func (c *PlaneRemovalConfig) Validate() error {
+	if c.Distance <= 0 {
+		return fmt.Errorf("plane removal needs a distance")
+	}
+	return nil
+}
+
+// RemovePlane returns pc without its largest plane, a nil config leaves pc alone, and so does not finding a plane.
+func (c *PlaneRemovalConfig) RemovePlane(pc pointcloud.PointCloud) (pointcloud.PointCloud, error) {
+	if c == nil || pc.Size() < 3 {
+		return pc, nil
+	}
+
+	_, inliers, outliers, err := SegmentPlane(pc, c.Distance, c.iterations())
+	if errors.Is(err, ErrNoPlane) {
+		return pc, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if inliers.Size() < c.MinInliers {
+		return pc, nil
+	}
+
+	return outliers, nil
+}
@@ -0,0 +1,114 @@
+package touch
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/test"
+)
+
+// makeTiltedTableCloud makes a table tilted 20 degrees around x with a box sitting above it.
+func makeTiltedTableCloud() (pointcloud.PointCloud, int) {
+	pc := pointcloud.NewBasicEmpty()
+
+	angle := 20 * math.Pi / 180
+	for x := 0; x < 100; x++ {
+		for y := 0; y < 100; y++ {
+			fy := float64(y * 3)
+			pc.Set(r3.Vector{X: float64(x * 3), Y: fy * math.Cos(angle), Z: fy*math.Sin(angle) + 100}, pointcloud.NewBasicData())
+		}
+	}
+	tableSize := pc.Size()
+
+	for x := 0; x < 20; x++ {
+		for y := 0; y < 20; y++ {
+			for z := 0; z < 20; z++ {
+				pc.Set(r3.Vector{X: 100 + float64(x*2), Y: 100 + float64(y*2), Z: 250 + float64(z*2)}, pointcloud.NewBasicData())
+			}
+		}
+	}
+
+	return pc, tableSize
+}
+
+func TestSegmentPlane(t *testing.T) {
+	pc, tableSize := makeTiltedTableCloud()
+
+	plane, inliers, outliers, err := SegmentPlane(pc, 1, 200)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, inliers.Size(), test.ShouldEqual, tableSize)
+	test.That(t, outliers.Size(), test.ShouldEqual, pc.Size()-tableSize)
+
+	angle := 20 * math.Pi / 180
+	expected := r3.Vector{Y: -math.Sin(angle), Z: math.Cos(angle)}
+	test.That(t, math.Abs(plane.Normal.Dot(expected)), test.ShouldAlmostEqual, 1, .0001)
+	test.That(t, plane.Distance(r3.Vector{Z: 100}), test.ShouldAlmostEqual, 0, .0001)
+
+	_, _, _, err = SegmentPlane(pc, 0, 200)
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func TestSegmentPlanes(t *testing.T) {
+	pc, tableSize := makeTiltedTableCloud()
+
+	planes, remaining, err := SegmentPlanes(pc, 1, 200, 3, 300)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(planes), test.ShouldEqual, 3)
+	test.That(t, planes[0].Inliers.Size(), test.ShouldEqual, tableSize)
+
+	total := remaining.Size()
+	for _, p := range planes {
+		total += p.Inliers.Size()
+	}
+	test.That(t, total, test.ShouldEqual, pc.Size())
+}
+
+func TestPlaneRemovalConfig(t *testing.T) {
+	pc, tableSize := makeTiltedTableCloud()
+
+	var none *PlaneRemovalConfig
+	out, err := none.RemovePlane(pc)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, out.Size(), test.ShouldEqual, pc.Size())
+
+	c := &PlaneRemovalConfig{}
+	test.That(t, c.Validate(), test.ShouldNotBeNil)
+
+	c.Distance = 1
+	test.That(t, c.Validate(), test.ShouldBeNil)
+
+	out, err = c.RemovePlane(pc)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, out.Size(), test.ShouldEqual, pc.Size()-tableSize)
+
+	c.MinInliers = tableSize + 1
+	out, err = c.RemovePlane(pc)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, out.Size(), test.ShouldEqual, pc.Size())
+
+	cc := &ClusterConfig{MaxDistance: 5, MinPointsPerSegment: 1, MinPointsPerCluster: 100}
+	clusters, err := cc.Cluster(pc)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(clusters), test.ShouldEqual, 2)
+
+	cc.RemovePlane = &PlaneRemovalConfig{Distance: 1}
+	clusters, err = cc.Cluster(pc)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(clusters), test.ShouldEqual, 1)
+	test.That(t, clusters[0].Size(), test.ShouldEqual, pc.Size()-tableSize)
+
+	// no plane in a line, so it's left alone
+	line := pointcloud.NewBasicPointCloud(0)
+	for i := 0; i < 10; i++ {
+		test.That(t, line.Set(r3.Vector{X: float64(i)}, pointcloud.NewBasicData()), test.ShouldBeNil)
+	}
+	_, _, _, err = SegmentPlane(line, 1, 200)
+	test.That(t, errors.Is(err, ErrNoPlane), test.ShouldBeTrue)
+	out, err = c.RemovePlane(line)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, out.Size(), test.ShouldEqual, 10)
+}
@@ -0,0 +1,319 @@
+package touch
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/golang/geo/r3"
+
+	toggleswitch "go.viam.com/rdk/components/switch"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/referenceframe"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/robot/framesystem"
+	"go.viam.com/rdk/services/generic"
+	"go.viam.com/rdk/services/motion"
+	"go.viam.com/rdk/spatialmath"
+
+	"github.com/erh/vmodutils"
+)
+
+var ScanPlannerModel = vmodutils.NamespaceFamily.WithModel("scan-pose-planner")
+
+func init() {
+	resource.RegisterService(
+		generic.API,
+		ScanPlannerModel,
+		resource.Registration[resource.Resource, *ScanPlannerConfig]{
+			Constructor: newScanPlanner,
+		})
+}
+
+// ScanPoseOptions says where to put a camera to look at a region.
+type ScanPoseOptions struct {
+	Count            int     // how many poses, defaults to 5
+	FOVDegrees       float64 // the camera's narrowest field of view, defaults to 60
+	ElevationDegrees float64 // how far from straight down every pose but the first is, defaults to 30
+	Distance         float64 // from the center of the region, defaults to far enough to see the whole region
+}
+
+func (o ScanPoseOptions) count() int {
+	if o.Count <= 0 {
+		return 5
+	}
+	return o.Count
+}
+
+func (o ScanPoseOptions) fov() float64 {
+	if o.FOVDegrees <= 0 {
+		return 60
+	}
+	return o.FOVDegrees
+}
+
+func (o ScanPoseOptions) elevation() float64 {
+	if o.ElevationDegrees <= 0 {
+		return 30
+	}
+	return o.ElevationDegrees
+}
+
+// GenerateScanPoses returns camera poses, in the same frame as min and max, that look at the center of the region
+// from different angles. The camera looks along its z axis. The first pose is straight above the region,
+// the rest are evenly spaced in a ring around it.
+func GenerateScanPoses(min, max r3.Vector, opts ScanPoseOptions) []spatialmath.Pose {
+	center := min.Add(max).Mul(.5)
+
+	distance := opts.Distance
+	if distance <= 0 {
+		radius := max.Sub(min).Norm() / 2
+		distance = radius / math.Tan(opts.fov()*math.Pi/360)
+	}
+
+	lookingFrom := func(dir r3.Vector) spatialmath.Pose {
+		return spatialmath.NewPose(
+			center.Add(dir.Mul(distance)),
+			&spatialmath.OrientationVectorDegrees{OX: -dir.X, OY: -dir.Y, OZ: -dir.Z},
+		)
+	}
+
+	poses := []spatialmath.Pose{lookingFrom(r3.Vector{Z: 1})}
+
+	ring := opts.count() - 1
+	e := opts.elevation() * math.Pi / 180
+	for i := 0; i < ring; i++ {
+		a := 2 * math.Pi * float64(i) / float64(ring)
+		poses = append(poses, lookingFrom(r3.Vector{
+			X: math.Sin(e) * math.Cos(a),
+			Y: math.Sin(e) * math.Sin(a),
+			Z: math.Cos(e),
+		}))
+	}
+
+	return poses
+}
+
+type ScanPlannerConfig struct {
+	Arm    string `json:"arm"`
+	Camera string `json:"camera"` // has to be attached to the arm in the frame system
+	Motion string `json:"motion"` // defaults to builtin
+
+	// the region to look at, in world
+	Min r3.Vector `json:"min"`
+	Max r3.Vector `json:"max"`
+
+	Count            int     `json:"count"`
+	FOVDegrees       float64 `json:"fov_degrees"`
+	ElevationDegrees float64 `json:"elevation_degrees"`
+	Distance         float64 `json:"distance"`
+
+	PlanTimeoutSeconds float64                `json:"plan_timeout_seconds"` // per pose, defaults to 10
+	Extra              map[string]interface{} `json:"extra"`                // passed to the motion service
+}
+
+func (c *ScanPlannerConfig) motion() string {
+	if c.Motion == "" {
+		return "builtin"
+	}
+	return c.Motion
+}
+
+func (c *ScanPlannerConfig) planTimeout() time.Duration {
+	if c.PlanTimeoutSeconds <= 0 {
+		return 10 * time.Second
+	}
+	return time.Duration(c.PlanTimeoutSeconds * float64(time.Second))
+}
+
+func (c *ScanPlannerConfig) options() ScanPoseOptions {
+	return ScanPoseOptions{
+		Count:            c.Count,
+		FOVDegrees:       c.FOVDegrees,
+		ElevationDegrees: c.ElevationDegrees,
+		Distance:         c.Distance,
+	}
+}
+
+func (c *ScanPlannerConfig) Validate(path string) ([]string, []string, error) {
+	if c.Arm == "" {
+		return nil, nil, fmt.Errorf("need an arm")
+	}
+	if c.Camera == "" {
+		return nil, nil, fmt.Errorf("need a camera")
+	}
+	if c.Min.X >= c.Max.X || c.Min.Y >= c.Max.Y || c.Min.Z >= c.Max.Z {
+		return nil, nil, fmt.Errorf("min has to be less than max")
+	}
+	if c.FOVDegrees < 0 || c.FOVDegrees >= 180 {
+		return nil, nil, fmt.Errorf("fov_degrees has to be less than 180")
+	}
+	if c.ElevationDegrees < 0 || c.ElevationDegrees >= 90 {
+		return nil, nil, fmt.Errorf("elevation_degrees has to be less than 90")
+	}
+
+	return []string{c.Arm, motion.Named(c.motion()).String(), framesystem.PublicServiceName.String()}, nil, nil
+}
+
+func newScanPlanner(ctx context.Context, deps resource.Dependencies, config resource.Config, logger logging.Logger) (resource.Resource, error) {
+	newConf, err := resource.NativeConfig[*ScanPlannerConfig](config)
+	if err != nil {
+		return nil, err
+	}
+
+	sp := &ScanPlanner{
+		name:   config.ResourceName(),
+		cfg:    newConf,
+		logger: logger,
+	}
+
+	sp.motion, err = motion.FromProvider(deps, newConf.motion())
+	if err != nil {
+		return nil, err
+	}
+
+	fs, err := framesystem.FromDependencies(deps)
+	if err != nil {
+		return nil, err
+	}
+	sp.transformer = NewLocalFrameTransformer(fs)
+
+	return sp, nil
+}
+
+type ScanPlanner struct {
+	resource.AlwaysRebuild
+	resource.TriviallyCloseable
+
+	name   resource.Name
+	cfg    *ScanPlannerConfig
+	logger logging.Logger
+
+	motion      motion.Service
+	transformer *LocalFrameTransformer
+}
+
+// ScanPose is an arm pose that puts the camera at one of the GenerateScanPoses.
+type ScanPose struct {
+	Pose      spatialmath.Pose // of the arm, in world
+	Reachable bool
+	Err       error // why it isn't reachable
+}
+
+func (sp *ScanPlanner) Name() resource.Name {
+	return sp.name
+}
+
+// DoCommand
+//
+//	{"plan" : true} - returns the arm poses, if each is reachable, and an arm-position-saver config for it
+//	{"save" : "<prefix>"} - adds an arm-position-saver named <prefix>-<n> to the machine for each reachable pose
+func (sp *ScanPlanner) DoCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	if cmd["plan"] == true {
+		poses, err := sp.Plan(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		res := []interface{}{}
+		for _, p := range poses {
+			m := map[string]interface{}{
+				"point":       p.Pose.Point(),
+				"orientation": p.Pose.Orientation().OrientationVectorDegrees(),
+				"reachable":   p.Reachable,
+				"config":      sp.positionSaverAttributes(p.Pose),
+			}
+			if p.Err != nil {
+				m["error"] = p.Err.Error()
+			}
+			res = append(res, m)
+		}
+		return map[string]interface{}{"poses": res}, nil
+	}
+
+	if prefix, ok := cmd["save"].(string); ok {
+		poses, err := sp.Plan(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		names := []string{}
+		components := []map[string]interface{}{}
+		for _, p := range poses {
+			if !p.Reachable {
+				continue
+			}
+			name := fmt.Sprintf("%s-%d", prefix, len(names))
+			names = append(names, name)
+			components = append(components, map[string]interface{}{
+				"name":       name,
+				"api":        toggleswitch.API.String(),
+				"model":      ArmPositionSaverModel.String(),
+				"attributes": sp.positionSaverAttributes(p.Pose),
+			})
+		}
+
+		if len(components) == 0 {
+			return nil, fmt.Errorf("no reachable poses to save")
+		}
+
+		err = vmodutils.AddComponentsCloudFromModuleEnv(ctx, components, sp.logger)
+		if err != nil {
+			return nil, err
+		}
+
+		return map[string]interface{}{"saved": names}, nil
+	}
+
+	return nil, fmt.Errorf("unknown command %v", cmd)
+}
+
+func (sp *ScanPlanner) positionSaverAttributes(p spatialmath.Pose) map[string]interface{} {
+	return map[string]interface{}{
+		"arm":         sp.cfg.Arm,
+		"motion":      sp.cfg.motion(),
+		"point":       p.Point(),
+		"orientation": p.Orientation().OrientationVectorDegrees(),
+	}
+}
+
+// Plan finds the arm poses that look at the region and checks if the motion service can get to each one.
+func (sp *ScanPlanner) Plan(ctx context.Context) ([]ScanPose, error) {
+	err := sp.transformer.Update(ctx, []string{sp.cfg.Arm, sp.cfg.Camera})
+	if err != nil {
+		return nil, err
+	}
+
+	// where the arm is from the camera's point of view, it doesn't change as the arm moves
+	cameraToArm, err := sp.transformer.RelativePose(sp.cfg.Arm, sp.cfg.Camera)
+	if err != nil {
+		return nil, err
+	}
+
+	poses := []ScanPose{}
+	for _, cp := range GenerateScanPoses(sp.cfg.Min, sp.cfg.Max, sp.cfg.options()) {
+		p := ScanPose{Pose: spatialmath.Compose(cp, cameraToArm)}
+		p.Err = sp.checkReachable(ctx, p.Pose)
+		p.Reachable = p.Err == nil
+		poses = append(poses, p)
+
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+	}
+
+	return poses, nil
+}
+
+func (sp *ScanPlanner) checkReachable(ctx context.Context, p spatialmath.Pose) error {
+	ctx, cancel := context.WithTimeout(ctx, sp.cfg.planTimeout())
+	defer cancel()
+
+	_, err := PlanMove(ctx, sp.motion, motion.MoveReq{
+		ComponentName: sp.cfg.Arm,
+		Destination:   referenceframe.NewPoseInFrame(referenceframe.World, p),
+		Extra:         sp.cfg.Extra,
+	})
+	return err
+}
@@ -0,0 +1,131 @@
+package touch
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/referenceframe"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/services/motion"
+	"go.viam.com/rdk/spatialmath"
+	"go.viam.com/test"
+)
+
+// fakeMotionService only supports the plan DoCommand, GetPose, and Move, which goes straight to the destination.
+type fakeMotionService struct {
+	motion.Service
+	plan  func(req string) (interface{}, error)
+	pose  spatialmath.Pose
+	moves []motion.MoveReq
+}
+
+func (f *fakeMotionService) GetPose(ctx context.Context, componentName, destinationFrame string, supplementalTransforms []*referenceframe.LinkInFrame, extra map[string]interface{}) (*referenceframe.PoseInFrame, error) {
+	return referenceframe.NewPoseInFrame(destinationFrame, f.pose), nil
+}
+
+func (f *fakeMotionService) Move(ctx context.Context, req motion.MoveReq) (bool, error) {
+	f.moves = append(f.moves, req)
+	f.pose = req.Destination.Pose()
+	return true, nil
+}
+
+func (f *fakeMotionService) Name() resource.Name {
+	return motion.Named("builtin")
+}
+
+func (f *fakeMotionService) DoCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	plan, err := f.plan(cmd["plan"].(string))
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"plan": plan}, nil
+}
+
+func TestGenerateScanPoses(t *testing.T) {
+	min := r3.Vector{X: -10, Y: -10, Z: 0}
+	max := r3.Vector{X: 10, Y: 10, Z: 20}
+	center := r3.Vector{Z: 10}
+
+	poses := GenerateScanPoses(min, max, ScanPoseOptions{Count: 4, FOVDegrees: 90})
+	test.That(t, len(poses), test.ShouldEqual, 4)
+
+	// with a 90 degree fov, the distance is the radius of the region
+	radius := max.Sub(min).Norm() / 2
+	test.That(t, poses[0].Point().X, test.ShouldAlmostEqual, 0)
+	test.That(t, poses[0].Point().Z, test.ShouldAlmostEqual, 10+radius)
+
+	for _, p := range poses {
+		test.That(t, p.Point().Distance(center), test.ShouldAlmostEqual, radius)
+
+		// the camera's z axis points at the center
+		ahead := spatialmath.Compose(p, spatialmath.NewPoseFromPoint(r3.Vector{Z: radius})).Point()
+		test.That(t, ahead.Distance(center), test.ShouldBeLessThan, 1e-6)
+	}
+
+	// the ring is 30 degrees from straight down by default
+	test.That(t, poses[1].Point().Z-10, test.ShouldAlmostEqual, radius*math.Cos(math.Pi/6))
+	test.That(t, poses[1].Point().Distance(poses[2].Point()), test.ShouldAlmostEqual, poses[2].Point().Distance(poses[3].Point()))
+
+	poses = GenerateScanPoses(min, max, ScanPoseOptions{Distance: 100})
+	test.That(t, len(poses), test.ShouldEqual, 5)
+	test.That(t, poses[0].Point().Z, test.ShouldAlmostEqual, 110)
+}
+
+func TestScanPlanner(t *testing.T) {
+	ctx := context.Background()
+
+	cfg := &ScanPlannerConfig{
+		Arm:        "gantry",
+		Camera:     "cam",
+		Min:        r3.Vector{X: -10, Y: -10, Z: 0},
+		Max:        r3.Vector{X: 10, Y: 10, Z: 20},
+		Count:      3,
+		Distance:   100,
+		FOVDegrees: 60,
+	}
+	deps, _, err := cfg.Validate("")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, deps, test.ShouldContain, motion.Named("builtin").String())
+
+	plans := 0
+	sp := &ScanPlanner{
+		cfg:    cfg,
+		logger: logging.NewTestLogger(t),
+		motion: &fakeMotionService{plan: func(req string) (interface{}, error) {
+			plans++
+			if plans == 2 {
+				return nil, errors.New("can't get there")
+			}
+			return []interface{}{}, nil
+		}},
+		transformer: NewLocalFrameTransformer(newFakeFrameSystemService(t)),
+	}
+
+	poses, err := sp.Plan(ctx)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(poses), test.ShouldEqual, 3)
+	test.That(t, plans, test.ShouldEqual, 3)
+	test.That(t, poses[0].Reachable, test.ShouldBeTrue)
+	test.That(t, poses[1].Reachable, test.ShouldBeFalse)
+	test.That(t, poses[1].Err, test.ShouldNotBeNil)
+
+	// the camera is 10 along the gantry's z, so looking down from 110 puts the gantry at 120
+	test.That(t, poses[0].Pose.Point().Z, test.ShouldAlmostEqual, 120)
+
+	res, err := sp.DoCommand(ctx, map[string]interface{}{"plan": true})
+	test.That(t, err, test.ShouldBeNil)
+	list := res["poses"].([]interface{})
+	test.That(t, len(list), test.ShouldEqual, 3)
+	first := list[0].(map[string]interface{})
+	test.That(t, first["reachable"], test.ShouldBeTrue)
+	test.That(t, first["config"].(map[string]interface{})["arm"], test.ShouldEqual, "gantry")
+
+	cfg.Min = cfg.Max
+	_, _, err = cfg.Validate("")
+	test.That(t, err, test.ShouldNotBeNil)
+}
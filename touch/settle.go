@@ -0,0 +1,123 @@
+package touch
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/components/arm"
+	"go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/pointcloud"
+)
+
+// SettleConfig waits for the arm to stop and the point cloud to stop changing before capturing, instead of a fixed sleep.
+type SettleConfig struct {
+	// Tolerance is how far (mm) the center or a corner of the bounds of consecutive point clouds can move
+	// and still count as settled.
+	Tolerance float64 `json:"tolerance"`
+	// TimeoutSeconds is how long to wait for things to settle before falling back to the fixed sleep, defaults to 5.
+	TimeoutSeconds float64 `json:"timeout_seconds"`
+}
+
+func (c *SettleConfig) Validate() error {
+	if c.Tolerance <= 0 {
+		return fmt.Errorf("settle needs a tolerance")
+	}
+	if c.TimeoutSeconds < 0 {
+		return fmt.Errorf("settle timeout_seconds can't be negative")
+	}
+	return nil
+}
+
+func (c *SettleConfig) timeout() time.Duration {
+	if c.TimeoutSeconds <= 0 {
+		return 5 * time.Second
+	}
+	return time.Duration(c.TimeoutSeconds * float64(time.Second))
+}
+
+// CaptureTiming is how long each step of a capture took.
+type CaptureTiming struct {
+	Move    time.Duration
+	Settle  time.Duration // waiting for the arm and point cloud, or the fixed sleep
+	Settled bool          // false if the fixed sleep was used
+}
+
+func (ct CaptureTiming) toMap() map[string]interface{} {
+	return map[string]interface{}{
+		"move_ms":   ct.Move.Milliseconds(),
+		"settle_ms": ct.Settle.Milliseconds(),
+		"settled":   ct.Settled,
+	}
+}
+
+// pointCloudChange is the furthest the center or a corner of the bounds moved between two point clouds,
+// so a view that shakes or turns around a steady center still changes.
+// Two empty point clouds haven't changed, but an empty and a non-empty one have changed infinitely.
+func pointCloudChange(a, b pointcloud.PointCloud) float64 {
+	if a.Size() == 0 || b.Size() == 0 {
+		if a.Size() == b.Size() {
+			return 0
+		}
+		return math.Inf(1)
+	}
+	ma := a.MetaData()
+	mb := b.MetaData()
+
+	change := ma.Center().Distance(mb.Center())
+	change = math.Max(change, r3.Vector{X: ma.MinX, Y: ma.MinY, Z: ma.MinZ}.Distance(r3.Vector{X: mb.MinX, Y: mb.MinY, Z: mb.MinZ}))
+	change = math.Max(change, r3.Vector{X: ma.MaxX, Y: ma.MaxY, Z: ma.MaxZ}.Distance(r3.Vector{X: mb.MaxX, Y: mb.MaxY, Z: mb.MaxZ}))
+	return change
+}
+
+// waitForSettle waits for a (if not nil) to stop moving, then for two point clouds in a row from src to be within
+// cfg.Tolerance of each other, and returns the last one.
+// If it times out it returns context.DeadlineExceeded, but the parent context's error takes priority.
+func waitForSettle(ctx context.Context, a arm.Arm, src camera.Camera, extra map[string]interface{}, cfg *SettleConfig) (pointcloud.PointCloud, error) {
+	sctx, cancel := context.WithTimeout(ctx, cfg.timeout())
+	defer cancel()
+
+	pc, err := doWaitForSettle(sctx, a, src, extra, cfg)
+	if err != nil && ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	return pc, err
+}
+
+func doWaitForSettle(ctx context.Context, a arm.Arm, src camera.Camera, extra map[string]interface{}, cfg *SettleConfig) (pointcloud.PointCloud, error) {
+	if a != nil {
+		for {
+			moving, err := a.IsMoving(ctx)
+			if err != nil {
+				return nil, err
+			}
+			if !moving {
+				break
+			}
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(20 * time.Millisecond):
+			}
+		}
+	}
+
+	var last pointcloud.PointCloud
+	for {
+		pc, err := src.NextPointCloud(ctx, extra)
+		if err != nil {
+			return nil, err
+		}
+		if last != nil && pointCloudChange(last, pc) <= cfg.Tolerance {
+			return pc, nil
+		}
+		last = pc
+
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package touch
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/geo/r3"
+
+	toggleswitch "go.viam.com/rdk/components/switch"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/test"
+)
+
+// convergingCamera returns a point that gets closer to the origin each time, until it stops at xs' end.
+func convergingCamera(xs ...float64) *fakePointCloudCamera {
+	calls := 0
+	return &fakePointCloudCamera{nextPointCloud: func(ctx context.Context, extra map[string]interface{}) (pointcloud.PointCloud, error) {
+		x := xs[min(calls, len(xs)-1)]
+		calls++
+		pc := pointcloud.NewBasicEmpty()
+		return pc, pc.Set(r3.Vector{X: x}, nil)
+	}}
+}
+
+func TestWaitForSettle(t *testing.T) {
+	ctx := context.Background()
+	cfg := &SettleConfig{Tolerance: 1}
+	test.That(t, cfg.Validate(), test.ShouldBeNil)
+	test.That(t, (&SettleConfig{}).Validate(), test.ShouldNotBeNil)
+
+	a := &fakeArm{moving: 3}
+	pc, err := waitForSettle(ctx, a, convergingCamera(100, 20, 5, 4.5), nil, cfg)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, a.moving, test.ShouldEqual, 0)
+	_, got := pc.At(4.5, 0, 0)
+	test.That(t, got, test.ShouldBeTrue)
+
+	// never settles
+	calls := 0
+	jitter := &fakePointCloudCamera{nextPointCloud: func(ctx context.Context, extra map[string]interface{}) (pointcloud.PointCloud, error) {
+		calls++
+		pc := pointcloud.NewBasicEmpty()
+		return pc, pc.Set(r3.Vector{X: float64(calls%2) * 10}, nil)
+	}}
+	_, err = waitForSettle(ctx, nil, jitter, nil, &SettleConfig{Tolerance: 1, TimeoutSeconds: .01})
+	test.That(t, errors.Is(err, context.DeadlineExceeded), test.ShouldBeTrue)
+
+	// turns around a steady center, so never settles
+	calls = 0
+	turning := &fakePointCloudCamera{nextPointCloud: func(ctx context.Context, extra map[string]interface{}) (pointcloud.PointCloud, error) {
+		calls++
+		pc := pointcloud.NewBasicEmpty()
+		if calls%2 == 0 {
+			return pc, errors.Join(pc.Set(r3.Vector{X: -10}, nil), pc.Set(r3.Vector{X: 10}, nil))
+		}
+		return pc, errors.Join(pc.Set(r3.Vector{Y: -10}, nil), pc.Set(r3.Vector{Y: 10}, nil))
+	}}
+	_, err = waitForSettle(ctx, nil, turning, nil, &SettleConfig{Tolerance: 1, TimeoutSeconds: .01})
+	test.That(t, errors.Is(err, context.DeadlineExceeded), test.ShouldBeTrue)
+
+	// nothing in view is settled once it stays empty
+	calls = 0
+	emptied := &fakePointCloudCamera{nextPointCloud: func(ctx context.Context, extra map[string]interface{}) (pointcloud.PointCloud, error) {
+		calls++
+		pc := pointcloud.NewBasicEmpty()
+		if calls == 1 {
+			return pc, pc.Set(r3.Vector{X: 5}, nil)
+		}
+		return pc, nil
+	}}
+	pc, err = waitForSettle(ctx, nil, emptied, nil, cfg)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, pc.Size(), test.ShouldEqual, 0)
+	test.That(t, calls, test.ShouldEqual, 3)
+
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = waitForSettle(cctx, &fakeArm{moving: 1000}, jitter, nil, cfg)
+	test.That(t, errors.Is(err, context.Canceled), test.ShouldBeTrue)
+}
+
+func TestCapturePointCloudsSettle(t *testing.T) {
+	ctx := context.Background()
+	positions := []toggleswitch.Switch{
+		&fakeSwitch{setPosition: func(uint32) {}},
+		&fakeSwitch{setPosition: func(uint32) {}},
+	}
+
+	pcs, timings, err := CapturePointCloudsWithOptions(ctx, positions, convergingCamera(10, 3, 2.5), CaptureOptions{
+		SleepTime: time.Millisecond,
+		Settle:    &SettleConfig{Tolerance: 1},
+		Arm:       &fakeArm{moving: 2},
+	})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(pcs), test.ShouldEqual, 2)
+	test.That(t, len(timings), test.ShouldEqual, 2)
+	test.That(t, timings[0].Settled, test.ShouldBeTrue)
+	test.That(t, timings[1].Settled, test.ShouldBeTrue)
+
+	// falls back to sleeping
+	calls := 0
+	jitter := &fakePointCloudCamera{nextPointCloud: func(ctx context.Context, extra map[string]interface{}) (pointcloud.PointCloud, error) {
+		calls++
+		pc := pointcloud.NewBasicEmpty()
+		return pc, pc.Set(r3.Vector{X: float64(calls%2) * 10}, nil)
+	}}
+	pcs, timings, err = CapturePointCloudsWithOptions(ctx, positions[:1], jitter, CaptureOptions{
+		SleepTime: 5 * time.Millisecond,
+		Settle:    &SettleConfig{Tolerance: 1, TimeoutSeconds: .01},
+		Logger:    logging.NewTestLogger(t),
+	})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(pcs), test.ShouldEqual, 1)
+	test.That(t, timings[0].Settled, test.ShouldBeFalse)
+	test.That(t, timings[0].Settle, test.ShouldBeGreaterThanOrEqualTo, 15*time.Millisecond)
+	test.That(t, timings[0].toMap()["settled"], test.ShouldBeFalse)
+}
@@ -0,0 +1,271 @@
+package touch
+
+import (
+	"math"
+	"sort"
+
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/pointcloud"
+)
+
+// voxelKey identifies a cube of a voxelGrid, cell (x,y,z) covers ((x-1)*size, x*size] on each axis.
+type voxelKey struct {
+	X, Y, Z int
+}
+
+func (k voxelKey) add(dx, dy, dz int) voxelKey {
+	return voxelKey{k.X + dx, k.Y + dy, k.Z + dz}
+}
+
+type voxelCell struct {
+	key    voxelKey
+	points []int // indexes into voxelGrid.points
+}
+
+// voxelGrid is a sparse spatial hash of points, used so neighbor lookups only look at nearby cells.
+type voxelGrid struct {
+	size float64
+
+	points []r3.Vector
+	data   []pointcloud.Data
+
+	cells []*voxelCell // in order first seen, so results are deterministic
+	index map[voxelKey]int
+}
+
+func newVoxelGrid(size float64) *voxelGrid {
+	return &voxelGrid{
+		size:  size,
+		index: map[voxelKey]int{},
+	}
+}
+
+func newVoxelGridFromPointCloud(pc pointcloud.PointCloud, size float64) *voxelGrid {
+	g := newVoxelGrid(size)
+	pc.Iterate(0, 0, func(p r3.Vector, d pointcloud.Data) bool {
+		g.add(p, d)
+		return true
+	})
+	return g
+}
+
+func (g *voxelGrid) keyFor(p r3.Vector) voxelKey {
+	return voxelKey{
+		int(math.Ceil(p.X / g.size)),
+		int(math.Ceil(p.Y / g.size)),
+		int(math.Ceil(p.Z / g.size)),
+	}
+}
+
+func (g *voxelGrid) add(p r3.Vector, d pointcloud.Data) {
+	k := g.keyFor(p)
+	idx, ok := g.index[k]
+	if !ok {
+		idx = len(g.cells)
+		g.index[k] = idx
+		g.cells = append(g.cells, &voxelCell{key: k})
+	}
+	c := g.cells[idx]
+	c.points = append(c.points, len(g.points))
+	g.points = append(g.points, p)
+	g.data = append(g.data, d)
+}
+
+func (g *voxelGrid) cell(k voxelKey) (int, *voxelCell) {
+	idx, ok := g.index[k]
+	if !ok {
+		return -1, nil
+	}
+	return idx, g.cells[idx]
+}
+
+// cellBounds returns the min and max corner of the cube for k.
+func (g *voxelGrid) cellBounds(k voxelKey) (r3.Vector, r3.Vector) {
+	max := r3.Vector{X: float64(k.X) * g.size, Y: float64(k.Y) * g.size, Z: float64(k.Z) * g.size}
+	min := r3.Vector{X: max.X - g.size, Y: max.Y - g.size, Z: max.Z - g.size}
+	return min, max
+}
+
+// forEachNeighbor calls fn with the index of every point within radius of p, stopping early if fn returns false.
+func (g *voxelGrid) forEachNeighbor(p r3.Vector, radius float64, fn func(i int) bool) {
+	r := int(math.Ceil(radius / g.size))
+	k := g.keyFor(p)
+	r2 := radius * radius
+
+	for dx := -r; dx <= r; dx++ {
+		for dy := -r; dy <= r; dy++ {
+			for dz := -r; dz <= r; dz++ {
+				_, c := g.cell(k.add(dx, dy, dz))
+				if c == nil {
+					continue
+				}
+				for _, i := range c.points {
+					if p.Sub(g.points[i]).Norm2() > r2 {
+						continue
+					}
+					if !fn(i) {
+						return
+					}
+				}
+			}
+		}
+	}
+}
+
+// neighbors returns the indexes of every point within radius of p.
+func (g *voxelGrid) neighbors(p r3.Vector, radius float64) []int {
+	res := []int{}
+	g.forEachNeighbor(p, radius, func(i int) bool {
+		res = append(res, i)
+		return true
+	})
+	return res
+}
+
+// kNearest returns the indexes of the k points closest to p, closest first, ignoring the point at index skip.
+func (g *voxelGrid) kNearest(p r3.Vector, k, skip int) []int {
+	if k <= 0 {
+		return nil
+	}
+
+	type candidate struct {
+		idx int
+		d2  float64
+	}
+	// best is kept sorted and at most k long
+	best := make([]candidate, 0, k)
+
+	addCell := func(c *voxelCell) {
+		for _, i := range c.points {
+			if i == skip {
+				continue
+			}
+			d2 := p.Sub(g.points[i]).Norm2()
+			if len(best) == k && d2 >= best[k-1].d2 {
+				continue
+			}
+			pos := sort.Search(len(best), func(x int) bool { return best[x].d2 > d2 })
+			if len(best) < k {
+				best = append(best, candidate{})
+			}
+			copy(best[pos+1:], best[pos:len(best)-1])
+			best[pos] = candidate{i, d2}
+		}
+	}
+
+	finish := func() []int {
+		res := make([]int, len(best))
+		for i, c := range best {
+			res[i] = c.idx
+		}
+		return res
+	}
+
+	center := g.keyFor(p)
+
+	for r := 0; ; r++ {
+		side := 2*r + 1
+		if side*side*side > len(g.cells) {
+			// the shells are bigger than the grid, just look at everything
+			best = best[:0]
+			for _, c := range g.cells {
+				addCell(c)
+			}
+			return finish()
+		}
+
+		for dx := -r; dx <= r; dx++ {
+			for dy := -r; dy <= r; dy++ {
+				for dz := -r; dz <= r; dz++ {
+					if max(abs(dx), abs(dy), abs(dz)) != r {
+						continue
+					}
+					_, c := g.cell(center.add(dx, dy, dz))
+					if c != nil {
+						addCell(c)
+					}
+				}
+			}
+		}
+
+		// anything outside the cells looked at so far is at least r*size away
+		limit := float64(r) * g.size
+		if len(best) == k && best[k-1].d2 <= limit*limit {
+			return finish()
+		}
+	}
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// subset returns a new point cloud with the points at idxs.
+func (g *voxelGrid) subset(idxs []int) (pointcloud.PointCloud, error) {
+	pc := pointcloud.NewBasicPointCloud(len(idxs))
+	for _, i := range idxs {
+		err := pc.Set(g.points[i], g.data[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return pc, nil
+}
+
+// distanceToBox returns how far p is from the axis aligned box, 0 if it's inside.
+func distanceToBox(p, min, max r3.Vector) float64 {
+	d := r3.Vector{
+		X: math.Max(0, math.Max(min.X-p.X, p.X-max.X)),
+		Y: math.Max(0, math.Max(min.Y-p.Y, p.Y-max.Y)),
+		Z: math.Max(0, math.Max(min.Z-p.Z, p.Z-max.Z)),
+	}
+	return d.Norm()
+}
+
+// unionFind is a disjoint set with path compression and union by rank.
+type unionFind struct {
+	parent []int
+	rank   []int
+}
+
+func newUnionFind(n int) *unionFind {
+	uf := &unionFind{
+		parent: make([]int, n),
+		rank:   make([]int, n),
+	}
+	for i := range uf.parent {
+		uf.parent[i] = i
+	}
+	return uf
+}
+
+func (uf *unionFind) find(x int) int {
+	for uf.parent[x] != x {
+		uf.parent[x] = uf.parent[uf.parent[x]]
+		x = uf.parent[x]
+	}
+	return x
+}
+
+// union joins the sets of a and b, returns false if they were already joined.
+func (uf *unionFind) union(a, b int) bool {
+	ra := uf.find(a)
+	rb := uf.find(b)
+	if ra == rb {
+		return false
+	}
+	switch {
+	case uf.rank[ra] < uf.rank[rb]:
+		uf.parent[ra] = rb
+	case uf.rank[ra] > uf.rank[rb]:
+		uf.parent[rb] = ra
+	default:
+		uf.parent[rb] = ra
+		uf.rank[ra]++
+	}
+	return true
+}
@@ -6,12 +6,15 @@ import (
 	"image"
 	"image/color"
 	"math"
+	"sort"
 	"time"
 
 	"github.com/golang/geo/r3"
 
+	"go.viam.com/rdk/components/arm"
 	"go.viam.com/rdk/components/camera"
 	toggleswitch "go.viam.com/rdk/components/switch"
+	"go.viam.com/rdk/logging"
 	"go.viam.com/rdk/pointcloud"
 	"go.viam.com/rdk/spatialmath"
 )
@@ -221,36 +224,292 @@ func GetApproachPoint(p r3.Vector, deltaLinear float64, o *spatialmath.Orientati
 }
 
 func GetMergedPointCloud(ctx context.Context, positions []toggleswitch.Switch, sleepTime time.Duration, src camera.Camera, extra map[string]interface{}) (pointcloud.PointCloud, error) {
+	inputs, err := CapturePointClouds(ctx, positions, sleepTime, src, extra)
+	if err != nil {
+		return nil, err
+	}
+
+	big, _, err := MergePointClouds(inputs, nil, 0)
+	return big, err
+}
+
+// CapturePointClouds moves to each position in order and gets a point cloud from src after sleepTime.
+func CapturePointClouds(ctx context.Context, positions []toggleswitch.Switch, sleepTime time.Duration, src camera.Camera, extra map[string]interface{}) ([]pointcloud.PointCloud, error) {
+	pcs, _, err := CapturePointCloudsWithOptions(ctx, positions, src, CaptureOptions{SleepTime: sleepTime, Extra: extra})
+	return pcs, err
+}
+
+// CaptureOptions configures CapturePointCloudsWithOptions.
+type CaptureOptions struct {
+	SleepTime time.Duration
+	Extra     map[string]interface{} // passed to the camera
+
+	// Settle, if set, is used instead of SleepTime, which is only used if things don't settle in time.
+	// Arm, if set, has to stop moving to be settled.
+	Settle *SettleConfig
+	Arm    arm.Arm
+	Logger logging.Logger // optional
+
+	// Home, if set, is visited between positions.
+	Home toggleswitch.Switch
+
+	// OnCapture, if set, is called with each point cloud before moving on, and its result is used instead.
+	OnCapture func(ctx context.Context, pc pointcloud.PointCloud) (pointcloud.PointCloud, error)
+}
+
+// CapturePointCloudsWithOptions is CapturePointClouds with settling, a home position, and a hook for each capture.
+// It also returns how long each capture took.
+func CapturePointCloudsWithOptions(ctx context.Context, positions []toggleswitch.Switch, src camera.Camera, opts CaptureOptions) ([]pointcloud.PointCloud, []CaptureTiming, error) {
 	inputs := []pointcloud.PointCloud{}
-	totalSize := 0
+	timings := []CaptureTiming{}
+
+	for i, p := range positions {
+		timing := CaptureTiming{}
+		start := time.Now()
 
-	for _, p := range positions {
+		if i > 0 && opts.Home != nil {
+			err := opts.Home.SetPosition(ctx, 2, nil)
+			if err != nil {
+				return nil, nil, fmt.Errorf("cannot go home: %w", err)
+			}
+		}
 
 		err := p.SetPosition(ctx, 2, nil)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
-		time.Sleep(sleepTime)
+		timing.Move = time.Since(start)
+		start = time.Now()
 
-		pc, err := src.NextPointCloud(ctx, extra)
-		if err != nil {
-			return nil, err
+		var pc pointcloud.PointCloud
+		if opts.Settle != nil {
+			pc, err = waitForSettle(ctx, opts.Arm, src, opts.Extra, opts.Settle)
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil, nil, err
+				}
+				if opts.Logger != nil {
+					opts.Logger.Warnf("position %d didn't settle, sleeping instead: %v", i, err)
+				}
+				pc = nil
+			} else {
+				timing.Settled = true
+			}
 		}
 
-		totalSize += pc.Size()
+		if pc == nil {
+			time.Sleep(opts.SleepTime)
+
+			pc, err = src.NextPointCloud(ctx, opts.Extra)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+
+		timing.Settle = time.Since(start)
+
+		if opts.OnCapture != nil {
+			pc, err = opts.OnCapture(ctx, pc)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
 
 		inputs = append(inputs, pc)
+		timings = append(timings, timing)
+	}
+
+	return inputs, timings, nil
+}
+
+// MergePointClouds combines pcs into one point cloud.
+// If fuseRadius is positive, points from different clouds within fuseRadius of each other become one point
+// at their weighted average position and color, where weights has one weight per cloud (nil means all 1).
+// It returns how many points were fused into another point.
+func MergePointClouds(pcs []pointcloud.PointCloud, weights []float64, fuseRadius float64) (pointcloud.PointCloud, int, error) {
+	if weights != nil && len(weights) != len(pcs) {
+		return nil, 0, fmt.Errorf("got %d weights for %d point clouds", len(weights), len(pcs))
+	}
+
+	totalSize := 0
+	for _, pc := range pcs {
+		totalSize += pc.Size()
+	}
+
+	if fuseRadius <= 0 {
+		big := pointcloud.NewBasicPointCloud(totalSize)
+
+		for _, pc := range pcs {
+			err := pointcloud.ApplyOffset(pc, nil, big)
+			if err != nil {
+				return nil, 0, err
+			}
+		}
+
+		return big, 0, nil
+	}
+
+	g := newVoxelGrid(fuseRadius)
+	sources := make([]int, 0, totalSize)
+	pointWeights := make([]float64, 0, totalSize)
+	for i, pc := range pcs {
+		w := 1.0
+		if weights != nil {
+			w = weights[i]
+		}
+		pc.Iterate(0, 0, func(p r3.Vector, d pointcloud.Data) bool {
+			g.add(p, d)
+			sources = append(sources, i)
+			pointWeights = append(pointWeights, w)
+			return true
+		})
+	}
+
+	fused := make([]bool, len(g.points))
+	merged := 0
+
+	// points that end up in the same spot are one output point, so they're collected and each spot is set once
+	spots := map[r3.Vector]int{}
+	groups := [][]int{}
+	centers := []r3.Vector{}
+
+	for i, p := range g.points {
+		if fused[i] {
+			continue
+		}
+
+		// the closest point from each other cloud joins this one
+		closest := map[int]int{}
+		g.forEachNeighbor(p, fuseRadius, func(j int) bool {
+			if fused[j] || sources[j] == sources[i] {
+				return true
+			}
+			old, ok := closest[sources[j]]
+			if !ok || p.Distance(g.points[j]) < p.Distance(g.points[old]) {
+				closest[sources[j]] = j
+			}
+			return true
+		})
+
+		group := []int{i}
+		for _, j := range closest {
+			group = append(group, j)
+		}
+		sort.Ints(group)
+
+		total := 0.0
+		center := r3.Vector{}
+		for _, j := range group {
+			fused[j] = true
+			center = center.Add(g.points[j].Mul(pointWeights[j]))
+			total += pointWeights[j]
+		}
+		if total > 0 {
+			center = center.Mul(1 / total)
+		} else {
+			center = p
+		}
+
+		// landing on a point that's already there fuses the whole group into it
+		if k, ok := spots[center]; ok {
+			groups[k] = append(groups[k], group...)
+			merged += len(group)
+			continue
+		}
+		merged += len(group) - 1
+		spots[center] = len(groups)
+		groups = append(groups, group)
+		centers = append(centers, center)
+	}
+
+	out := pointcloud.NewBasicPointCloud(len(groups))
+	for k, group := range groups {
+		sort.Ints(group)
+		err := out.Set(centers[k], weightedAverageData(g.data, group, pointWeights))
+		if err != nil {
+			return nil, 0, err
+		}
 	}
 
-	big := pointcloud.NewBasicPointCloud(totalSize)
+	return out, merged, nil
+}
+
+const (
+	VoxelModeCentroid = "centroid"
+	VoxelModeNearest  = "nearest"
+)
+
+// PCVoxelDownsample keeps one point per cube of side voxelSize, with the average color of the points in it.
+// mode VoxelModeCentroid (default) uses the average position, VoxelModeNearest uses the real point closest to the center of the cube.
+func PCVoxelDownsample(pc pointcloud.PointCloud, voxelSize float64, mode string) (pointcloud.PointCloud, error) {
+	if voxelSize <= 0 {
+		return nil, fmt.Errorf("voxelSize has to be positive, not %v", voxelSize)
+	}
+	if mode != "" && mode != VoxelModeCentroid && mode != VoxelModeNearest {
+		return nil, fmt.Errorf("unknown voxel mode [%s]", mode)
+	}
+
+	g := newVoxelGridFromPointCloud(pc, voxelSize)
+
+	out := pointcloud.NewBasicPointCloud(len(g.cells))
+
+	for _, c := range g.cells {
+		var p r3.Vector
+		if mode == VoxelModeNearest {
+			min, max := g.cellBounds(c.key)
+			center := min.Add(max).Mul(.5)
+			best := math.Inf(1)
+			for _, i := range c.points {
+				d := g.points[i].Sub(center).Norm2()
+				if d < best {
+					best = d
+					p = g.points[i]
+				}
+			}
+		} else {
+			for _, i := range c.points {
+				p = p.Add(g.points[i])
+			}
+			p = p.Mul(1 / float64(len(c.points)))
+		}
 
-	for _, pc := range inputs {
-		err := pointcloud.ApplyOffset(pc, nil, big)
+		err := out.Set(p, averageData(g.data, c.points))
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	return big, nil
+	return out, nil
+}
+
+// averageData averages the color of the points at idxs that have one.
+func averageData(data []pointcloud.Data, idxs []int) pointcloud.Data {
+	return weightedAverageData(data, idxs, nil)
+}
+
+// weightedAverageData is averageData with a weight per point, nil weights means all 1.
+func weightedAverageData(data []pointcloud.Data, idxs []int, weights []float64) pointcloud.Data {
+	var r, g, b, n float64
+	for _, i := range idxs {
+		d := data[i]
+		if d == nil || !d.HasColor() {
+			continue
+		}
+		w := 1.0
+		if weights != nil {
+			w = weights[i]
+		}
+		cr, cg, cb := d.RGB255()
+		r += w * float64(cr)
+		g += w * float64(cg)
+		b += w * float64(cb)
+		n += w
+	}
+
+	if n <= 0 {
+		return pointcloud.NewBasicData()
+	}
+
+	return pointcloud.NewColoredData(color.NRGBA{uint8(r / n), uint8(g / n), uint8(b / n), 255})
 }
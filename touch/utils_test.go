@@ -193,3 +193,105 @@ func TestPCCropColor(t *testing.T) {
 	test.That(t, filtered.Size(), test.ShouldBeLessThan, in.Size())
 
 }
+
+func TestPCVoxelDownsample(t *testing.T) {
+	pc := pointcloud.NewBasicEmpty()
+	pc.Set(r3.Vector{X: 1, Y: 1, Z: 1}, pointcloud.NewColoredData(color.NRGBA{200, 0, 0, 255}))
+	pc.Set(r3.Vector{X: 3, Y: 3, Z: 3}, pointcloud.NewColoredData(color.NRGBA{0, 100, 0, 255}))
+	pc.Set(r3.Vector{X: 6, Y: 6, Z: 6}, pointcloud.NewBasicData())
+	pc.Set(r3.Vector{X: 7, Y: 6, Z: 6}, pointcloud.NewBasicData())
+
+	out, err := PCVoxelDownsample(pc, 5, "")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, out.Size(), test.ShouldEqual, 2)
+
+	d, got := out.At(2, 2, 2)
+	test.That(t, got, test.ShouldBeTrue)
+	test.That(t, d.HasColor(), test.ShouldBeTrue)
+	r, g, b := d.RGB255()
+	test.That(t, r, test.ShouldEqual, 100)
+	test.That(t, g, test.ShouldEqual, 50)
+	test.That(t, b, test.ShouldEqual, 0)
+
+	d, got = out.At(6.5, 6, 6)
+	test.That(t, got, test.ShouldBeTrue)
+	test.That(t, d.HasColor(), test.ShouldBeFalse)
+
+	out, err = PCVoxelDownsample(pc, 5, VoxelModeNearest)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, out.Size(), test.ShouldEqual, 2)
+	_, got = out.At(3, 3, 3)
+	test.That(t, got, test.ShouldBeTrue)
+	_, got = out.At(7, 6, 6)
+	test.That(t, got, test.ShouldBeTrue)
+
+	_, err = PCVoxelDownsample(pc, 0, "")
+	test.That(t, err, test.ShouldNotBeNil)
+	_, err = PCVoxelDownsample(pc, 5, "foo")
+	test.That(t, err, test.ShouldNotBeNil)
+
+	in, err := pointcloud.NewFromFile("data/glass1.pcd", "")
+	test.That(t, err, test.ShouldBeNil)
+	out, err = PCVoxelDownsample(in, 5, "")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, out.Size(), test.ShouldBeLessThan, in.Size()/2)
+}
+
+func TestMergePointClouds(t *testing.T) {
+	a := pointcloud.NewBasicEmpty()
+	a.Set(r3.Vector{X: 0}, pointcloud.NewColoredData(color.NRGBA{200, 0, 0, 255}))
+	a.Set(r3.Vector{X: 1}, pointcloud.NewColoredData(color.NRGBA{200, 0, 0, 255}))
+	a.Set(r3.Vector{X: 50}, pointcloud.NewColoredData(color.NRGBA{200, 0, 0, 255}))
+
+	b := pointcloud.NewBasicEmpty()
+	b.Set(r3.Vector{X: 3}, pointcloud.NewColoredData(color.NRGBA{0, 100, 0, 255}))
+	b.Set(r3.Vector{X: 100}, pointcloud.NewColoredData(color.NRGBA{0, 100, 0, 255}))
+
+	out, fused, err := MergePointClouds([]pointcloud.PointCloud{a, b}, nil, 0)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, out.Size(), test.ShouldEqual, 5)
+	test.That(t, fused, test.ShouldEqual, 0)
+
+	// points in the same cloud are never fused, the closest point from b joins the first point from a
+	out, fused, err = MergePointClouds([]pointcloud.PointCloud{a, b}, nil, 5)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, out.Size(), test.ShouldEqual, 4)
+	test.That(t, fused, test.ShouldEqual, 1)
+
+	d, got := out.At(1.5, 0, 0)
+	test.That(t, got, test.ShouldBeTrue)
+	r, g, _ := d.RGB255()
+	test.That(t, r, test.ShouldEqual, 100)
+	test.That(t, g, test.ShouldEqual, 50)
+	_, got = out.At(1, 0, 0)
+	test.That(t, got, test.ShouldBeTrue)
+
+	// b counts 3 times as much
+	out, _, err = MergePointClouds([]pointcloud.PointCloud{a, b}, []float64{1, 3}, 5)
+	test.That(t, err, test.ShouldBeNil)
+	d, got = out.At(2.25, 0, 0)
+	test.That(t, got, test.ShouldBeTrue)
+	r, g, _ = d.RGB255()
+	test.That(t, r, test.ShouldEqual, 50)
+	test.That(t, g, test.ShouldEqual, 75)
+
+	_, _, err = MergePointClouds([]pointcloud.PointCloud{a, b}, []float64{1}, 5)
+	test.That(t, err, test.ShouldNotBeNil)
+
+	// a fused point landing on another point fuses with it too, so the counts always add up
+	c := pointcloud.NewBasicEmpty()
+	c.Set(r3.Vector{X: 0}, pointcloud.NewColoredData(color.NRGBA{90, 0, 0, 255}))
+	c.Set(r3.Vector{X: 1}, pointcloud.NewColoredData(color.NRGBA{0, 90, 0, 255}))
+	e := pointcloud.NewBasicEmpty()
+	e.Set(r3.Vector{X: 2}, pointcloud.NewColoredData(color.NRGBA{0, 0, 90, 255}))
+	out, fused, err = MergePointClouds([]pointcloud.PointCloud{c, e}, nil, 5)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, out.Size(), test.ShouldEqual, 1)
+	test.That(t, fused, test.ShouldEqual, 2)
+	test.That(t, out.Size(), test.ShouldEqual, c.Size()+e.Size()-fused)
+
+	d, got = out.At(1, 0, 0)
+	test.That(t, got, test.ShouldBeTrue)
+	r, g, bl := d.RGB255()
+	test.That(t, []uint8{r, g, bl}, test.ShouldResemble, []uint8{30, 30, 30})
+}